@@ -0,0 +1,406 @@
+// Package analysis recovers enigma keys from intercepted traffic by statistical attacks on the ciphertext.
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jtraynor/enigma"
+)
+
+// Attack recovers a key from ciphertext alone in the style of Gillogly and of Weierud and Sullivan. Every rotor order
+// and start position is ranked by the index of coincidence of its decryption with no plugs, then the ring settings of
+// the best are hill climbed to raise it further, and finally the plugs are found by hill climbing on n-gram scores.
+// The more plugs the key has the longer the message needs to be: a few hundred letters are enough with 5 plugs, while
+// a full set of 10 needs much more.
+type Attack struct {
+	// Reflectors are the names of the reflectors to try. Defaults to B.
+	Reflectors []string
+	// Rotors are the names of the rotors to try in every order. Defaults to I - V.
+	Rotors []string
+	// Keep is the number of rotor settings carried forward from the first stage. Defaults to 50.
+	Keep int
+	// Plugs is the most plugs a key may have. Defaults to enigma.HistoricalPlugLimit.
+	Plugs int
+	// Scorer scores the decryptions while the plugs are found and ranks the results. Defaults to German trigrams.
+	Scorer Scorer
+	// Workers is the number of settings tried at once. Defaults to GOMAXPROCS.
+	Workers int
+	// Checkpoint, if set, is the path of a file the progress of the attack and the best settings and results found so
+	// far are saved to at intervals and when the attack stops, whether it finished, was cancelled or failed.
+	Checkpoint string
+	// Interval is how often the checkpoint is saved. Defaults to a minute.
+	Interval time.Duration
+	// Resume carries on the attack from the checkpoint, if the file exists, rather than starting again. It must be
+	// resumed with the same scorer.
+	Resume bool
+}
+
+// Result is a key recovered by an attack, along with the rotor positions the message starts at, the score of the
+// decryption and the decryption itself. Higher scores are better.
+type Result struct {
+	Key       enigma.Key
+	Positions string
+	Score     float64
+	Plaintext string
+}
+
+// A rotor setting being carried through the attack.
+type setting struct {
+	reflector string
+	rotors    [3]string
+	rings     [3]int
+	positions string
+	score     float64
+}
+
+// Run attacks the ciphertext and returns a result for each of the best rotor settings, best first. Anything in the
+// ciphertext other than letters is ignored. If the context is cancelled the attack stops early, returning the results
+// found so far along with the context's error. Returns an error if there is no ciphertext, the attack uses a reflector
+// or rotors that do not exist, the checkpoint being resumed is for a different attack or the checkpoint could not be
+// saved.
+func (a Attack) Run(ctx context.Context, ciphertext string) ([]Result, error) {
+	text := enigma.AppendLetters(nil, []byte(ciphertext))
+	if len(text) == 0 {
+		return nil, fmt.Errorf("no ciphertext: %s", ciphertext)
+	}
+
+	settings, err := a.settings()
+	if err != nil {
+		return nil, err
+	}
+
+	progress := a.checkpoint(text, settings)
+
+	if a.Resume && len(a.Checkpoint) > 0 {
+		saved := checkpoint{}
+
+		err := enigma.LoadCheckpoint(a.Checkpoint, &saved)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// Nothing has been saved yet, so the attack starts from the beginning
+		case err != nil:
+			return nil, err
+		case !reflect.DeepEqual(saved.Attack, progress.Attack) || saved.Cursor < 0 ||
+			saved.Cursor > len(settings)+len(saved.Settings) || len(saved.Settings) > a.keep():
+			return nil, fmt.Errorf("checkpoint is for a different attack: %s", a.Checkpoint)
+		default:
+			progress = saved
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	saved := time.Now()
+	var failed error
+
+	// Saves the checkpoint if it is due, stopping the attack if it could not be saved
+	save := func() {
+		if len(a.Checkpoint) > 0 && failed == nil && time.Since(saved) >= a.interval() {
+			failed = enigma.SaveCheckpoint(a.Checkpoint, progress)
+			if failed != nil {
+				cancel()
+			}
+
+			saved = time.Now()
+		}
+	}
+
+	ranked := make([][]setting, len(settings))
+
+	a.parallel(ctx, progress.Cursor, len(settings), func(i int) {
+		ranked[i] = rank(settings[i], text, a.keep())
+	}, func(i int) {
+		progress.rank(ranked[i])
+		ranked[i] = nil
+		save()
+	})
+
+	if progress.Cursor >= len(settings) {
+		best := progress.best()
+		solved := make([]Result, len(best))
+
+		a.parallel(ctx, progress.Cursor-len(settings), len(best), func(i int) {
+			solved[i] = a.solve(best[i], text)
+		}, func(i int) {
+			progress.solve(solved[i])
+			save()
+		})
+	}
+
+	if len(a.Checkpoint) > 0 && failed == nil {
+		failed = enigma.SaveCheckpoint(a.Checkpoint, progress)
+	}
+
+	results := progress.results()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if failed != nil {
+		return results, failed
+	}
+
+	return results, ctx.Err()
+}
+
+// Returns how often the checkpoint is saved.
+func (a Attack) interval() time.Duration {
+	if a.Interval <= 0 {
+		return time.Minute
+	}
+
+	return a.Interval
+}
+
+// Returns the number of rotor settings to carry forward from the first stage.
+func (a Attack) keep() int {
+	if a.Keep < 1 {
+		return 50
+	}
+
+	return a.Keep
+}
+
+// Returns every reflector and order of 3 different rotors to try, with all rings set to 1.
+func (a Attack) settings() ([]setting, error) {
+	reflectors := a.Reflectors
+	if len(reflectors) == 0 {
+		reflectors = []string{"B"}
+	}
+
+	rotors := a.Rotors
+	if len(rotors) == 0 {
+		rotors = []string{"I", "II", "III", "IV", "V"}
+	}
+
+	orders, err := enigma.RotorOrders(rotors)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := []setting{}
+	for _, reflector := range reflectors {
+		// The rotors are all checked, so each reflector is checked with any one order of them
+		_, err = enigma.NewKey(reflector, orders[0], [3]int{1, 1, 1}, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, order := range orders {
+			settings = append(settings, setting{reflector: reflector, rotors: order, rings: [3]int{1, 1, 1}})
+		}
+	}
+
+	return settings, nil
+}
+
+// Runs the job for every index from first to count across the workers, calling done with each index in order once
+// its job and every job before it have finished. Done is called from the goroutine that called parallel, never
+// concurrently. No more jobs are started once the context is cancelled.
+func (a Attack) parallel(ctx context.Context, first, count int, job func(i int), done func(i int)) {
+	workers := a.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	jobs := make(chan int)
+	finished := make(chan int)
+
+	go func() {
+		defer close(jobs)
+
+		for i := first; i < count && ctx.Err() == nil; i++ {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < min(workers, count); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				job(i)
+				finished <- i
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	// Jobs finish out of order, so those after the next wait here until every job before them has finished
+	waiting := map[int]bool{}
+	next := first
+
+	for i := range finished {
+		waiting[i] = true
+
+		for waiting[next] {
+			delete(waiting, next)
+			done(next)
+			next++
+		}
+	}
+}
+
+// Adjusts the rings of a rotor setting and finds its plugs, returning the result.
+func (a Attack) solve(s setting, text []byte) Result {
+	options := RecoverOptions{
+		Scorer: a.Scorer,
+		Plugs:  a.Plugs,
+	}
+
+	scorer := options.scorer()
+
+	// Once most of the plugs are in, the rings are checked again, as a wrong ring garbles part of every 26 letters
+	s = adjustRings(s, text, newBoard(), IndexOfCoincidence{})
+
+	b, _ := options.recover(messageTables(s, len(text)), text)
+
+	s = adjustRings(s, text, b, scorer)
+
+	tables := messageTables(s, len(text))
+	b, _ = climbPlugs(tables, text, b, options.limit(), scorer)
+
+	plaintext := b.decrypt(tables, text, nil)
+
+	key, err := enigma.NewKey(s.reflector, s.rotors, s.rings, b.String())
+	if err != nil {
+		return Result{Score: scorer.Score(plaintext)}
+	}
+
+	return Result{
+		Key:       key,
+		Positions: s.positions,
+		Score:     scorer.Score(plaintext),
+		Plaintext: string(plaintext),
+	}
+}
+
+// Hill climbs the ring settings, trying every ring of the middle rotor then the right rotor until neither helps. The
+// rotor is turned along with its ring so that its wiring stays where it was and only the point at which the next rotor
+// steps changes, which is why the rotor to its left is also tried a position either side. The left ring makes no
+// difference, as nothing steps after the left rotor. Returns the setting whose decryption through the plugs scores
+// highest.
+func adjustRings(s setting, text []byte, b board, scorer Scorer) setting {
+	key, err := enigma.NewKey(s.reflector, s.rotors, s.rings, b.String())
+	if err != nil {
+		return s
+	}
+
+	machine, err := key.Machine(s.positions)
+	if err != nil {
+		return s
+	}
+
+	buffer := machine.AppendEncode(nil, text)
+	s.score = scorer.Score(buffer)
+
+	for improved := true; improved; {
+		improved = false
+
+		for _, rotor := range []int{1, 2} {
+			best := s
+
+			// Only the middle rotor can be out by a position itself, from the right rotor stepping it early or late
+			turns := []int{0}
+			if rotor == 1 {
+				turns = []int{-1, 0, 1}
+			}
+
+			for ring := 1; ring <= 26; ring++ {
+				for _, own := range turns {
+					for shift := -1; shift <= 1; shift++ {
+						trial := s
+						trial.rings[rotor] = ring
+
+						positions := []byte(s.positions)
+						positions[rotor] = turn(positions[rotor], ring-s.rings[rotor]+own)
+						positions[rotor-1] = turn(positions[rotor-1], shift)
+						trial.positions = string(positions)
+
+						key, err = enigma.NewKey(trial.reflector, trial.rotors, trial.rings, b.String())
+						if err != nil {
+							continue
+						}
+
+						err = machine.Reset(key, trial.positions)
+						if err != nil {
+							continue
+						}
+
+						buffer = machine.AppendEncode(buffer[:0], text)
+						trial.score = scorer.Score(buffer)
+
+						if trial.score > best.score {
+							best = trial
+						}
+					}
+				}
+			}
+
+			improved = improved || best != s
+			s = best
+		}
+	}
+
+	return s
+}
+
+// Returns the substitution the rotors make for each letter of a message with the setting, with no plugs.
+func messageTables(s setting, length int) [][26]byte {
+	tables := make([][26]byte, length)
+
+	key, err := enigma.NewKey(s.reflector, s.rotors, s.rings, "")
+	if err != nil {
+		return tables
+	}
+
+	machine, err := key.Machine(s.positions)
+	if err != nil {
+		return tables
+	}
+
+	for i := range tables {
+		machine.Step()
+
+		permutation := machine.Permutation()
+		for j := range tables[i] {
+			tables[i][j] = permutation[j] - 'A'
+		}
+	}
+
+	return tables
+}
+
+// Sorts rotor settings with the highest score first.
+func sortSettings(settings []setting) {
+	sort.SliceStable(settings, func(i, j int) bool {
+		return settings[i].score > settings[j].score
+	})
+}
+
+// Returns the letter turned by a number of positions, wrapping around from Z to A.
+func turn(letter byte, by int) byte {
+	return byte('A' + ((int(letter-'A')+by)%26+26)%26)
+}
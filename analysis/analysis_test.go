@@ -0,0 +1,285 @@
+package analysis_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtraynor/enigma"
+	"github.com/jtraynor/enigma/analysis"
+)
+
+const testPlaintext = "DASOBERKOMMANDODERWEHRMACHTGIBTBEKANNTXIMOSTENHABENUNSERETRUPPENDENANGRIFFDESFEINDESAMFRUEHEN" +
+	"MORGENABGEWIESENUNDDABEIZAHLREICHEGEFANGENEGEMACHTXDIEVERLUSTEDESGEGNERSSINDSCHWERXINDERNACHTWURDENDIESTELLUNGEN" +
+	"DERDRITTENDIVISIONVERSTAERKTUNDDIEVERSORGUNGMITMUNITIONUNDVERPFLEGUNGSICHERGESTELLTXDASWETTERBLEIBTKLARUNDKALTMIT" +
+	"LEICHTEMWINDAUSOSTENXWEITEREMELDUNGENFOLGENAMABENDXDERKOMMANDEURDERARMEEGRUPPEERWARTETBERICHTUEBERDIELAGEAN" +
+	"DERFRONTBISZEHNUHR"
+
+// Returns a message encoded with a known key, for the attack to find again.
+func testCiphertext(t *testing.T, plugs string) string {
+	key, err := enigma.NewKey("B", [3]string{"II", "I", "III"}, [3]int{1, 7, 19}, plugs)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	machine, err := key.Machine("KWM")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	return string(machine.AppendEncode(nil, []byte(testPlaintext)))
+}
+
+func TestAttack(t *testing.T) {
+	ciphertext := testCiphertext(t, "AQ BT CW DM FZ")
+
+	a := analysis.Attack{Rotors: []string{"I", "II", "III"}}
+
+	results, err := a.Run(context.Background(), ciphertext)
+	if err != nil {
+		t.Fatalf("Failed attack. Error: %v.", err)
+	}
+
+	if len(results) == 0 || results[0].Plaintext != testPlaintext {
+		t.Fatalf("Failed attack.\nExpected: %s.\nResult:   %v.", testPlaintext, results)
+	}
+
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("Failed ranking. Result %d scored %v after %v.", i, results[i].Score, results[i-1].Score)
+		}
+	}
+}
+
+func TestAttackCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := analysis.Attack{}.Run(ctx, "ABCDE")
+	if err != context.Canceled {
+		t.Errorf("Failed cancel.\nExpected: %v.\nResult:   %v.", context.Canceled, err)
+	}
+}
+
+func TestAttackCheckpoint(t *testing.T) {
+	ciphertext := testCiphertext(t, "AQ BT")
+
+	a := analysis.Attack{
+		Rotors:     []string{"I", "II", "III"},
+		Keep:       2,
+		Checkpoint: filepath.Join(t.TempDir(), "attack.json"),
+		Interval:   time.Nanosecond,
+	}
+
+	expected, err := a.Run(context.Background(), ciphertext)
+	if err != nil || len(expected) != 2 {
+		t.Fatalf("Setup Failed: %v, %v.", expected, err)
+	}
+
+	// Wind the checkpoint back to before the last setting was solved, marking the result of the first so that it shows
+	// whether the jobs before the cursor are run again
+	data, err := os.ReadFile(a.Checkpoint)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	saved := map[string]any{}
+
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	results := saved["results"].([]any)
+	first := results[0].(map[string]any)
+	first["plaintext"] = "RESUMED"
+
+	saved["cursor"] = saved["cursor"].(float64) - 1
+	saved["results"] = results[:1]
+
+	data, err = json.Marshal(saved)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = os.WriteFile(a.Checkpoint, data, 0o644)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	a.Resume = true
+
+	resumed, err := a.Run(context.Background(), ciphertext)
+	if err != nil {
+		t.Fatalf("Failed resume. Error: %v.", err)
+	}
+
+	marked := 0
+	for i := range resumed {
+		if resumed[i].Plaintext == "RESUMED" {
+			resumed[i].Plaintext = expected[i].Plaintext
+			marked++
+		}
+	}
+
+	if marked != 1 || !reflect.DeepEqual(resumed, expected) {
+		t.Errorf("Failed resume.\nExpected: %v.\nResult:   %v.", expected, resumed)
+	}
+
+	_, err = a.Run(context.Background(), ciphertext[1:])
+	if err == nil {
+		t.Errorf("Failed resume of a different attack. Expected an error.")
+	}
+}
+
+func TestRecoverPlugboard(t *testing.T) {
+	plugs := "AQ BT CW DM FZ GY HN KU LS OX"
+	ciphertext := testCiphertext(t, plugs)
+
+	key, err := enigma.NewKey("B", [3]string{"II", "I", "III"}, [3]int{1, 7, 19}, "")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	recovery, err := analysis.RecoverPlugboard(key, "KWM", ciphertext, analysis.RecoverOptions{Restarts: 2})
+	if err != nil {
+		t.Fatalf("Failed recovery. Error: %v.", err)
+	}
+
+	if recovery.Key.Plugs() != plugs {
+		t.Errorf("Failed plugs.\nExpected: %s.\nResult:   %s.", plugs, recovery.Key.Plugs())
+	}
+
+	if recovery.Plaintext != testPlaintext {
+		t.Errorf("Failed plaintext.\nExpected: %s.\nResult:   %s.", testPlaintext, recovery.Plaintext)
+	}
+
+	if len(recovery.Trace) != 3 {
+		t.Fatalf("Failed trace. Expected 3 climbs, got %d.", len(recovery.Trace))
+	}
+
+	for i, trace := range recovery.Trace {
+		for j := 1; j < len(trace); j++ {
+			if trace[j] <= trace[j-1] {
+				t.Errorf("Failed trace %d. Score %v after %v.", i, trace[j], trace[j-1])
+			}
+		}
+	}
+
+	_, err = analysis.RecoverPlugboard(key, "KW", ciphertext, analysis.RecoverOptions{})
+	if err == nil {
+		t.Errorf("Failed invalid positions. Expected an error.")
+	}
+}
+
+func TestAttackInvalid(t *testing.T) {
+	for name, a := range map[string]analysis.Attack{
+		"Reflector":   {Reflectors: []string{"Z"}},
+		"Rotor":       {Rotors: []string{"I", "II", "IX"}},
+		"Rotor Count": {Rotors: []string{"I", "II"}},
+	} {
+		_, err := a.Run(context.Background(), "ABCDE")
+		if err == nil {
+			t.Errorf("Failed %s. Expected an error.", name)
+		}
+	}
+
+	_, err := analysis.Attack{}.Run(context.Background(), "12 34")
+	if err == nil {
+		t.Errorf("Failed empty ciphertext. Expected an error.")
+	}
+}
+
+const testEnglish = "FOURSCOREANDSEVENYEARSAGOOURFATHERSBROUGHTFORTHONTHISCONTINENTANEWNATIONCONCEIVEDINLIBERTY"
+
+func TestBuiltin(t *testing.T) {
+	for size := 1; size <= 4; size++ {
+		german, err := analysis.Builtin("German", size)
+		if err != nil {
+			t.Fatalf("Failed German %d. Error: %v.", size, err)
+		}
+
+		english, err := analysis.Builtin("english", size)
+		if err != nil {
+			t.Fatalf("Failed English %d. Error: %v.", size, err)
+		}
+
+		if german.Size() != size || english.Size() != size {
+			t.Errorf("Failed size.\nExpected: %d.\nResult:   %d and %d.", size, german.Size(), english.Size())
+		}
+
+		text := []byte(testPlaintext[:len(testEnglish)])
+		if german.Score(text) <= english.Score(text) {
+			t.Errorf("Failed German %d. Scored %v against %v for English.", size, german.Score(text),
+				english.Score(text))
+		}
+
+		text = []byte(testEnglish)
+		if english.Score(text) <= german.Score(text) {
+			t.Errorf("Failed English %d. Scored %v against %v for German.", size, english.Score(text),
+				german.Score(text))
+		}
+	}
+
+	for _, language := range []string{"french", "german"} {
+		_, err := analysis.Builtin(language, 5)
+		if err == nil {
+			t.Errorf("Failed %s 5. Expected an error.", language)
+		}
+	}
+}
+
+func TestLoadNgrams(t *testing.T) {
+	bigrams, err := analysis.LoadNgrams(strings.NewReader("# Counts\r\nAB 3\r\n\r\nba 1\r\n"))
+	if err != nil {
+		t.Fatalf("Failed load. Error: %v.", err)
+	}
+
+	// Case and anything other than letters make no difference
+	expected := bigrams.Score([]byte("ABAB"))
+	if bigrams.Score([]byte("a-b A.b")) != expected {
+		t.Errorf("Failed score.\nExpected: %v.\nResult:   %v.", expected, bigrams.Score([]byte("a-b A.b")))
+	}
+
+	if bigrams.Score([]byte("ABAB")) <= bigrams.Score([]byte("ACAC")) {
+		t.Errorf("Failed score. Expected ABAB to beat ACAC.")
+	}
+
+	for name, table := range map[string]string{
+		"Empty":     "# Nothing\n",
+		"Count":     "AB x\n",
+		"Letters":   "A1 3\n",
+		"Sizes":     "AB 3\nABC 1\n",
+		"Too Large": "ABCDE 1\n",
+		"Fields":    "AB 3 4\n",
+	} {
+		_, err := analysis.LoadNgrams(strings.NewReader(table))
+		if err == nil {
+			t.Errorf("Failed %s. Expected an error.", name)
+		}
+	}
+}
+
+func TestIndexOfCoincidence(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected float64
+	}{
+		"Empty":     {"", 0},
+		"Same":      {"aaAA", 1},
+		"Different": {"ABCD", 0},
+		"Half":      {"A B-A B", 2.0 / 6.0},
+	}
+
+	for name, tc := range tests {
+		result := analysis.IndexOfCoincidence{}.Score([]byte(tc.input))
+		if result != tc.expected {
+			t.Errorf("Failed %s.\nExpected: %v.\nResult:   %v.", name, tc.expected, result)
+		}
+	}
+}
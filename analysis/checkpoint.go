@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"github.com/jtraynor/enigma"
+)
+
+// The progress of an attack saved to a file, so that it can be resumed after it stops. The jobs are ranking each rotor
+// order followed by solving each of the best settings, and every job before the cursor has finished. The settings are
+// the best ranked so far, best first, and the results are those of the settings solved so far.
+type checkpoint struct {
+	Attack   checkpointAttack    `json:"attack"`
+	Cursor   int                 `json:"cursor"`
+	Settings []checkpointSetting `json:"settings"`
+	Results  []checkpointResult  `json:"results"`
+}
+
+// The attack a checkpoint was saved from, so that it is never resumed by a different attack. The scorer cannot be
+// saved, so resuming with a different one carries on with the results of both.
+type checkpointAttack struct {
+	Ciphertext string      `json:"ciphertext"`
+	Settings   [][4]string `json:"settings"`
+	Keep       int         `json:"keep"`
+	Plugs      int         `json:"plugs"`
+}
+
+// A rotor setting saved in a checkpoint.
+type checkpointSetting struct {
+	Reflector string    `json:"reflector"`
+	Rotors    [3]string `json:"rotors"`
+	Rings     [3]int    `json:"rings"`
+	Positions string    `json:"positions"`
+	Score     float64   `json:"score"`
+}
+
+// A result saved in a checkpoint, with the setting and plugs of its key.
+type checkpointResult struct {
+	checkpointSetting
+	Plugs     string `json:"plugs"`
+	Plaintext string `json:"plaintext"`
+}
+
+// Returns an empty checkpoint for attacking the ciphertext from the rotor settings.
+func (a Attack) checkpoint(text []byte, settings []setting) checkpoint {
+	c := checkpoint{
+		Attack: checkpointAttack{
+			Ciphertext: string(text),
+			Settings:   [][4]string{},
+			Keep:       a.keep(),
+			Plugs:      a.Plugs,
+		},
+		Settings: []checkpointSetting{},
+		Results:  []checkpointResult{},
+	}
+
+	for _, s := range settings {
+		c.Attack.Settings = append(c.Attack.Settings, [4]string{s.reflector, s.rotors[0], s.rotors[1], s.rotors[2]})
+	}
+
+	return c
+}
+
+// Adds the ranked settings of the job at the cursor to the best so far, keeping the best of them, and moves the cursor
+// on. The settings are added after those of earlier jobs, so that ties keep the order they would have had if every
+// job were ranked at once.
+func (c *checkpoint) rank(ranked []setting) {
+	best := c.best()
+	best = append(best, ranked...)
+
+	sortSettings(best)
+	best = best[:min(len(best), c.Attack.Keep)]
+
+	c.Settings = c.Settings[:0]
+	for _, s := range best {
+		c.Settings = append(c.Settings, checkpointSetting{
+			Reflector: s.reflector,
+			Rotors:    s.rotors,
+			Rings:     s.rings,
+			Positions: s.positions,
+			Score:     s.score,
+		})
+	}
+
+	c.Cursor++
+}
+
+// Adds the result of the job at the cursor and moves the cursor on.
+func (c *checkpoint) solve(result Result) {
+	c.Results = append(c.Results, checkpointResult{
+		checkpointSetting: checkpointSetting{
+			Reflector: result.Key.Reflector(),
+			Rotors:    result.Key.Rotors(),
+			Rings:     result.Key.Rings(),
+			Positions: result.Positions,
+			Score:     result.Score,
+		},
+		Plugs:     result.Key.Plugs(),
+		Plaintext: result.Plaintext,
+	})
+
+	c.Cursor++
+}
+
+// Returns the best rotor settings ranked so far, best first.
+func (c checkpoint) best() []setting {
+	best := []setting{}
+	for _, s := range c.Settings {
+		best = append(best, setting{
+			reflector: s.Reflector,
+			rotors:    s.Rotors,
+			rings:     s.Rings,
+			positions: s.Positions,
+			score:     s.Score,
+		})
+	}
+
+	return best
+}
+
+// Returns the results of the settings solved so far, in the order they were solved.
+func (c checkpoint) results() []Result {
+	results := []Result{}
+	for _, r := range c.Results {
+		result := Result{Positions: r.Positions, Score: r.Score, Plaintext: r.Plaintext}
+
+		// The key is left empty when it could not be made, as it was when the result was found
+		key, err := enigma.NewKey(r.Reflector, r.Rotors, r.Rings, r.Plugs)
+		if err == nil {
+			result.Key = key
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
@@ -0,0 +1,197 @@
+package analysis
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed data/*.txt
+var tables embed.FS
+
+// The names of the built in tables for each size of n-gram.
+var ngramNames = map[int]string{
+	1: "unigrams",
+	2: "bigrams",
+	3: "trigrams",
+	4: "quadgrams",
+}
+
+// The built in tables, loaded the first time each is asked for.
+var builtin = struct {
+	sync.Mutex
+	tables map[string]*Ngrams
+}{tables: map[string]*Ngrams{}}
+
+// Ngrams scores text by the log probability of every run of letters of a single size: unigrams, bigrams, trigrams or
+// quadgrams. N-grams that never appeared in the text the table was counted from get a floor a little below the rarest
+// that did. An Ngrams is never changed once loaded and is safe to share.
+type Ngrams struct {
+	size   int
+	scores []float64
+}
+
+// LoadNgrams reads a table of n-gram counts, with one n-gram and its count separated by a space on each line. e.g.
+// "EIN 16927". Blank lines and lines starting with # are ignored. Returns an error if a line is invalid, the n-grams
+// are not all the same size or the size is not between 1 and 4.
+func LoadNgrams(r io.Reader) (*Ngrams, error) {
+	counts := map[string]float64{}
+	size := 0
+	total := 0.0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid n-gram line: %s", line)
+		}
+
+		gram := strings.ToUpper(fields[0])
+		for _, letter := range gram {
+			if letter < 'A' || letter > 'Z' {
+				return nil, fmt.Errorf("invalid n-gram: %s", fields[0])
+			}
+		}
+
+		if size == 0 {
+			size = len(gram)
+		}
+
+		if len(gram) != size {
+			return nil, fmt.Errorf("n-gram %s is not %d letters long", fields[0], size)
+		}
+
+		count, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || count <= 0 {
+			return nil, fmt.Errorf("invalid n-gram count: %s", line)
+		}
+
+		counts[gram] += count
+		total += count
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, check := ngramNames[size]; !check {
+		return nil, fmt.Errorf("invalid n-gram size: %d", size)
+	}
+
+	n := &Ngrams{
+		size:   size,
+		scores: make([]float64, int(math.Pow(26, float64(size)))),
+	}
+
+	floor := math.Log10(0.01 / total)
+	for i := range n.scores {
+		n.scores[i] = floor
+	}
+
+	for gram, count := range counts {
+		index := 0
+		for i := 0; i < size; i++ {
+			index = index*26 + int(gram[i]-'A')
+		}
+
+		n.scores[index] = math.Log10(count / total)
+	}
+
+	return n, nil
+}
+
+// Builtin returns one of the tables built into the package, counted from German or English text. The German text was
+// put through enigma.NormaliseGerman first, so the German tables score plaintext written out the way it was sent. The
+// header of each table gives its source and licence. The language is either german or english and the size is between
+// 1 for unigrams and 4 for quadgrams. Returns an error if there is no such table.
+func Builtin(language string, size int) (*Ngrams, error) {
+	name, check := ngramNames[size]
+	if !check {
+		return nil, fmt.Errorf("invalid n-gram size: %d", size)
+	}
+
+	language = strings.ToLower(language)
+	if language != "german" && language != "english" {
+		return nil, fmt.Errorf("no such language: %s", language)
+	}
+
+	path := "data/" + language + "_" + name + ".txt"
+
+	builtin.Lock()
+	defer builtin.Unlock()
+
+	if n, check := builtin.tables[path]; check {
+		return n, nil
+	}
+
+	file, err := tables.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	n, err := LoadNgrams(file)
+	if err != nil {
+		return nil, err
+	}
+
+	builtin.tables[path] = n
+
+	return n, nil
+}
+
+// Returns one of the tables built into the package, which are known to be valid.
+func mustBuiltin(language string, size int) *Ngrams {
+	n, err := Builtin(language, size)
+	if err != nil {
+		panic(err)
+	}
+
+	return n
+}
+
+// Size returns the number of letters in each n-gram.
+func (n *Ngrams) Size() int {
+	return n.size
+}
+
+// Score returns the sum of the log probabilities of every n-gram in the text. Lower case letters count as upper case
+// and anything else is skipped over. The closer the text is to the language, the higher the score.
+func (n *Ngrams) Score(text []byte) float64 {
+	modulus := len(n.scores) / 26
+	index := 0
+	filled := 0
+
+	score := 0.0
+	for _, letter := range text {
+		switch {
+		case letter >= 'A' && letter <= 'Z':
+			letter -= 'A'
+		case letter >= 'a' && letter <= 'z':
+			letter -= 'a'
+		default:
+			continue
+		}
+
+		index = index%modulus*26 + int(letter)
+
+		filled++
+		if filled >= n.size {
+			score += n.scores[index]
+		}
+	}
+
+	return score
+}
@@ -0,0 +1,262 @@
+package analysis
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/jtraynor/enigma"
+)
+
+// RecoverOptions controls how RecoverPlugboard searches for the plugs.
+type RecoverOptions struct {
+	// Scorer scores the decryptions. Defaults to German trigrams.
+	Scorer Scorer
+	// Plugs is the most plugs the board may have. Defaults to enigma.HistoricalPlugLimit.
+	Plugs int
+	// Restarts is the number of extra climbs, each from a different board of random plugs. Defaults to none.
+	Restarts int
+	// Seed seeds the random boards, so that the same seed always finds the same plugs.
+	Seed int64
+}
+
+// Recovery is the plugboard recovered for a key, along with the score of every climb made to find it.
+type Recovery struct {
+	Result
+	// Trace holds a list of scores for each climb by the scorer, starting with the score of the board the index of
+	// coincidence reached from the empty or random board and followed by the score after every change to the plugs
+	// that the scorer kept. The climb by the index of coincidence is not traced, as its scores are not comparable.
+	Trace [][]float64
+}
+
+// RecoverPlugboard finds the plugs for a key whose rotors, rings and start positions are already known, such as from a
+// bombe stop or a captured key list. Any plugs the key already has are ignored. Starting from an empty board it hill
+// climbs by changing the plugs of a pair of letters at a time, keeping each change that improves the score of the
+// decryption, then climbs again from random boards as many times as the options allow. Returns the best key found,
+// or an error if there is no ciphertext or the key or positions are invalid.
+func RecoverPlugboard(key enigma.Key, positions, ciphertext string, options RecoverOptions) (Recovery, error) {
+	text := enigma.AppendLetters(nil, []byte(ciphertext))
+	if len(text) == 0 {
+		return Recovery{}, fmt.Errorf("no ciphertext: %s", ciphertext)
+	}
+
+	s := setting{
+		reflector: key.Reflector(),
+		rotors:    key.Rotors(),
+		rings:     key.Rings(),
+		positions: positions,
+	}
+
+	unplugged, err := enigma.NewKey(s.reflector, s.rotors, s.rings, "")
+	if err != nil {
+		return Recovery{}, err
+	}
+
+	_, err = unplugged.Machine(positions)
+	if err != nil {
+		return Recovery{}, err
+	}
+
+	tables := messageTables(s, len(text))
+
+	b, trace := options.recover(tables, text)
+
+	plaintext := b.decrypt(tables, text, nil)
+
+	key, err = enigma.NewKey(s.reflector, s.rotors, s.rings, b.String())
+	if err != nil {
+		return Recovery{}, err
+	}
+
+	return Recovery{
+		Result: Result{
+			Key:       key,
+			Positions: positions,
+			Score:     options.scorer().Score(plaintext),
+			Plaintext: string(plaintext),
+		},
+		Trace: trace,
+	}, nil
+}
+
+// Returns the scorer to use.
+func (o RecoverOptions) scorer() Scorer {
+	if o.Scorer == nil {
+		return mustBuiltin("german", 3)
+	}
+
+	return o.Scorer
+}
+
+// Returns the most plugs the board may have.
+func (o RecoverOptions) limit() int {
+	if o.Plugs < 1 {
+		return enigma.HistoricalPlugLimit
+	}
+
+	return o.Plugs
+}
+
+// Climbs from an empty board and then from each random board, returning the best board found along with the trace of
+// every climb. Each climb uses the index of coincidence first, which finds the first few plugs reliably whatever the
+// language, then the scorer to finish the board.
+func (o RecoverOptions) recover(tables [][26]byte, text []byte) (board, [][]float64) {
+	random := rand.New(rand.NewSource(o.Seed))
+	scorer := o.scorer()
+
+	best := newBoard()
+	bestScore := 0.0
+	traces := [][]float64{}
+
+	for attempt := 0; attempt <= max(o.Restarts, 0); attempt++ {
+		b := newBoard()
+		if attempt > 0 {
+			b = randomBoard(random, o.limit()/2)
+		}
+
+		b, _ = climbPlugs(tables, text, b, o.limit(), IndexOfCoincidence{})
+
+		b, trace := climbPlugs(tables, text, b, o.limit(), scorer)
+		traces = append(traces, trace)
+
+		score := trace[len(trace)-1]
+		if attempt == 0 || score > bestScore {
+			best = b
+			bestScore = score
+		}
+	}
+
+	return best, traces
+}
+
+// Returns a board with a number of plugs between random letters.
+func randomBoard(random *rand.Rand, plugs int) board {
+	b := newBoard()
+
+	letters := random.Perm(26)
+	for i := 0; i < plugs; i++ {
+		b.connect(byte(letters[2*i]), byte(letters[2*i+1]))
+	}
+
+	return b
+}
+
+// A plugboard as the letter each letter is plugged to, which is itself if it has no plug.
+type board [26]byte
+
+func newBoard() board {
+	b := board{}
+	for i := range b {
+		b[i] = byte(i)
+	}
+
+	return b
+}
+
+// Returns the number of plugs on the board.
+func (b board) plugs() int {
+	count := 0
+	for i, other := range b {
+		if int(other) > i {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Returns the plugs as space separated letter pairs. e.g. "AB CD".
+func (b board) String() string {
+	pairs := []byte{}
+	for i, other := range b {
+		if int(other) > i {
+			if len(pairs) > 0 {
+				pairs = append(pairs, ' ')
+			}
+
+			pairs = append(pairs, byte('A'+i), 'A'+other)
+		}
+	}
+
+	return string(pairs)
+}
+
+// Connects two letters, unplugging whatever they were plugged to before.
+func (b *board) connect(x, y byte) {
+	b[b[x]] = b[x]
+	b[b[y]] = b[y]
+	b[x] = y
+	b[y] = x
+}
+
+// Decrypts the text through the plugs and the message tables, appending the result to dst.
+func (b *board) decrypt(tables [][26]byte, text []byte, dst []byte) []byte {
+	for i, letter := range text {
+		dst = append(dst, 'A'+b[tables[i][b[letter-'A']]])
+	}
+
+	return dst
+}
+
+// Hill climbs from the board, trying every pair of letters in turn: connecting them, disconnecting them if they are
+// already connected, and swapping partners if either is plugged elsewhere. Keeps any change that raises the score of
+// the decryption without going over the limit of plugs, until no change helps. Returns the board along with its
+// score before and after every change kept.
+func climbPlugs(tables [][26]byte, text []byte, b board, limit int, scorer Scorer) (board, []float64) {
+	buffer := b.decrypt(tables, text, nil)
+	best := scorer.Score(buffer)
+
+	trace := []float64{best}
+
+	for improved := true; improved; {
+		improved = false
+
+		for x := byte(0); x < 26; x++ {
+			for y := x + 1; y < 26; y++ {
+				for _, trial := range b.changes(x, y) {
+					if trial.plugs() > limit {
+						continue
+					}
+
+					buffer = trial.decrypt(tables, text, buffer[:0])
+
+					trialScore := scorer.Score(buffer)
+					if trialScore > best {
+						b = trial
+						best = trialScore
+						improved = true
+
+						trace = append(trace, best)
+					}
+				}
+			}
+		}
+	}
+
+	return b, trace
+}
+
+// Returns the boards that can be made from this one by changing the plugs of two letters.
+func (b board) changes(x, y byte) []board {
+	if b[x] == y {
+		unplugged := b
+		unplugged[x] = x
+		unplugged[y] = y
+
+		return []board{unplugged}
+	}
+
+	connected := b
+	connected.connect(x, y)
+
+	changes := []board{connected}
+
+	// The old partners of the two letters can be plugged to each other instead of being left without plugs
+	if b[x] != x && b[y] != y {
+		swapped := connected
+		swapped.connect(b[x], b[y])
+
+		changes = append(changes, swapped)
+	}
+
+	return changes
+}
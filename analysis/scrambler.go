@@ -0,0 +1,64 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/jtraynor/enigma"
+)
+
+// Decrypts the text from every start position with no plugs and returns the settings with the highest index of
+// coincidence, best first.
+func rank(base setting, text []byte, keep int) []setting {
+	key, err := enigma.NewKey(base.reflector, base.rotors, base.rings, "")
+	if err != nil {
+		return nil
+	}
+
+	s, err := key.Scrambler()
+	if err != nil {
+		return nil
+	}
+
+	scores := make([]int, enigma.PositionCount)
+
+	for start := range scores {
+		counts := [26]int{}
+
+		state := next(s, start)
+		for _, letter := range text {
+			counts[s.Table(state)[letter-'A']]++
+			state = next(s, state)
+		}
+
+		for _, count := range counts {
+			scores[start] += count * (count - 1)
+		}
+	}
+
+	starts := make([]int, enigma.PositionCount)
+	for i := range starts {
+		starts[i] = i
+	}
+
+	sort.SliceStable(starts, func(i, j int) bool {
+		return scores[starts[i]] > scores[starts[j]]
+	})
+
+	pairs := float64(max(len(text)*(len(text)-1), 1))
+
+	settings := make([]setting, min(keep, enigma.PositionCount))
+	for i := range settings {
+		settings[i] = base
+		settings[i].positions = enigma.PositionLetters(starts[i])
+		settings[i].score = float64(scores[starts[i]]) / pairs
+	}
+
+	return settings
+}
+
+// Returns the position index the right and middle rotors step on to from a position index, with the left rotor held
+// still. Until the middle ring is known it would turn at the wrong point and garble the rest of the message, whereas a
+// message of a few hundred letters rarely reaches the real turnover.
+func next(s *enigma.Scrambler, position int) int {
+	return position/676*676 + s.Next(position)%676
+}
@@ -1,150 +1,27 @@
-package enigma
-
-var availableRotors = map[string]rotor{
-	"I": rotor{
-		alphabetRing: [26]rune{
-			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-			'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-		},
-		substitutions: [26]rune{
-			'E', 'K', 'M', 'F', 'L', 'G', 'D', 'Q', 'V', 'Z', 'N', 'T', 'O',
-			'W', 'Y', 'H', 'X', 'U', 'S', 'P', 'A', 'I', 'B', 'R', 'C', 'J',
-		},
-		triggers: []rune{'R'},
-	},
-	"II": rotor{
-		alphabetRing: [26]rune{
-			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-			'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-		},
-		substitutions: [26]rune{
-			'A', 'J', 'D', 'K', 'S', 'I', 'R', 'U', 'X', 'B', 'L', 'H', 'W',
-			'T', 'M', 'C', 'Q', 'G', 'Z', 'N', 'P', 'Y', 'F', 'V', 'O', 'E',
-		},
-		triggers: []rune{'E'},
-	},
-	"III": rotor{
-		alphabetRing: [26]rune{
-			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-			'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-		},
-		substitutions: [26]rune{
-			'B', 'D', 'F', 'H', 'J', 'L', 'C', 'P', 'R', 'T', 'X', 'V', 'Z',
-			'N', 'Y', 'E', 'I', 'W', 'G', 'A', 'K', 'M', 'U', 'S', 'Q', 'O',
-		},
-		triggers: []rune{'V'},
-	},
-	"IV": rotor{
-		alphabetRing: [26]rune{
-			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-			'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-		},
-		substitutions: [26]rune{
-			'E', 'S', 'O', 'V', 'P', 'Z', 'J', 'A', 'Y', 'Q', 'U', 'I', 'R',
-			'H', 'X', 'L', 'N', 'F', 'T', 'G', 'K', 'D', 'C', 'M', 'W', 'B',
-		},
-		triggers: []rune{'J'},
-	},
-	"V": rotor{
-		alphabetRing: [26]rune{
-			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-			'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-		},
-		substitutions: [26]rune{
-			'V', 'Z', 'B', 'R', 'G', 'I', 'T', 'Y', 'U', 'P', 'S', 'D', 'N',
-			'H', 'L', 'X', 'A', 'W', 'M', 'J', 'Q', 'O', 'F', 'E', 'C', 'K',
-		},
-		triggers: []rune{'A'},
-	},
-	"VI": rotor{
-		alphabetRing: [26]rune{
-			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-			'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-		},
-		substitutions: [26]rune{
-			'J', 'P', 'G', 'V', 'O', 'U', 'M', 'F', 'Y', 'Q', 'B', 'E', 'N',
-			'H', 'Z', 'R', 'D', 'K', 'A', 'S', 'X', 'L', 'I', 'C', 'T', 'W',
-		},
-		triggers: []rune{'A', 'N'},
-	},
-	"VII": rotor{
-		alphabetRing: [26]rune{
-			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-			'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-		},
-		substitutions: [26]rune{
-			'N', 'Z', 'J', 'H', 'G', 'R', 'C', 'X', 'M', 'Y', 'S', 'W', 'B',
-			'O', 'U', 'F', 'A', 'I', 'V', 'L', 'P', 'E', 'K', 'Q', 'D', 'T',
-		},
-		triggers: []rune{'A', 'N'},
-	},
-	"VIII": rotor{
-		alphabetRing: [26]rune{
-			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-			'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-		},
-		substitutions: [26]rune{
-			'F', 'K', 'Q', 'H', 'T', 'L', 'X', 'O', 'C', 'B', 'J', 'S', 'P',
-			'D', 'Z', 'R', 'A', 'M', 'E', 'W', 'N', 'I', 'U', 'Y', 'G', 'V',
-		},
-		triggers: []rune{'A', 'N'},
-	},
-}
-
-var availableReflectors = map[string]rotor{
-	"B": rotor{
-		alphabetRing: [26]rune{
-			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-			'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-		},
-		substitutions: [26]rune{
-			'Y', 'R', 'U', 'H', 'Q', 'S', 'L', 'D', 'P', 'X', 'N', 'G', 'O',
-			'K', 'M', 'I', 'E', 'B', 'F', 'Z', 'C', 'W', 'V', 'J', 'A', 'T',
-		},
-	},
-	"C": rotor{
-		alphabetRing: [26]rune{
-			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-			'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-		},
-		substitutions: [26]rune{
-			'F', 'V', 'P', 'J', 'I', 'A', 'O', 'Y', 'E', 'D', 'R', 'Z', 'X',
-			'W', 'G', 'C', 'T', 'K', 'U', 'Q', 'S', 'B', 'N', 'M', 'H', 'L',
-		},
-	},
-}
-
-var rotorPositions = map[string]int{
-	"LEFT":   2,
-	"MIDDLE": 1,
-	"RIGHT":  0,
-}
-
-var runeToString = map[rune]string{
-	'A': "A",
-	'B': "B",
-	'C': "C",
-	'D': "D",
-	'E': "E",
-	'F': "F",
-	'G': "G",
-	'H': "H",
-	'I': "I",
-	'J': "J",
-	'K': "K",
-	'L': "L",
-	'M': "M",
-	'N': "N",
-	'O': "O",
-	'P': "P",
-	'Q': "Q",
-	'R': "R",
-	'S': "S",
-	'T': "T",
-	'U': "U",
-	'V': "V",
-	'W': "W",
-	'X': "X",
-	'Y': "Y",
-	'Z': "Z",
-}
+package enigma
+
+// The names of the available rotors and reflectors, in the order they are listed.
+var rotorNames = []string{"I", "II", "III", "IV", "V", "VI", "VII", "VIII"}
+var reflectorNames = []string{"B", "C"}
+
+var availableRotors = map[string]rotor{
+	"I":    newRotor("I", "EKMFLGDQVZNTOWYHXUSPAIBRCJ", "R", "Enigma I"),
+	"II":   newRotor("II", "AJDKSIRUXBLHWTMCQGZNPYFVOE", "E", "Enigma I"),
+	"III":  newRotor("III", "BDFHJLCPRTXVZNYEIWGAKMUSQO", "V", "Enigma I"),
+	"IV":   newRotor("IV", "ESOVPZJAYQUIRHXLNFTGKDCMWB", "J", "Enigma I"),
+	"V":    newRotor("V", "VZBRGITYUPSDNHLXAWMJQOFECK", "A", "Enigma I"),
+	"VI":   newRotor("VI", "JPGVOUMFYQBENHZRDKASXLICTW", "AN", "M3 Kriegsmarine"),
+	"VII":  newRotor("VII", "NZJHGRCXMYSWBOUFAIVLPEKQDT", "AN", "M3 Kriegsmarine"),
+	"VIII": newRotor("VIII", "FKQHTLXOCBJSPDZRAMEWNIUYGV", "AN", "M3 Kriegsmarine"),
+}
+
+var availableReflectors = map[string]rotor{
+	"B": newRotor("B", "YRUHQSLDPXNGOKMIEBFZCWVJAT", "", "Enigma I"),
+	"C": newRotor("C", "FVPJIAOYEDRZXWGCTKUQSBNMHL", "", "Enigma I"),
+}
+
+var rotorPositions = map[string]int{
+	"LEFT":   2,
+	"MIDDLE": 1,
+	"RIGHT":  0,
+}
@@ -0,0 +1,69 @@
+package enigma
+
+// Candidate is a key to try along with the positions of the left, middle and right rotors to start from.
+type Candidate struct {
+	Key       Key
+	Positions string
+}
+
+// DecryptAll decrypts the ciphertext with each of the candidates in turn and returns the plaintexts in the same order.
+// The plaintexts are upper case letters with no grouping. A single machine is reused for every candidate. Returns an
+// error if any of the candidates is invalid.
+func DecryptAll(ciphertext string, candidates []Candidate) ([]string, error) {
+	plaintexts := make([]string, len(candidates))
+
+	err := trialDecrypt(ciphertext, candidates, func(i int, plaintext []byte) {
+		plaintexts[i] = string(plaintext)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plaintexts, nil
+}
+
+// ScoreAll decrypts the ciphertext with each of the candidates in turn and returns the score the function gives each
+// plaintext, in the same order. The plaintext passed to the function is upper case letters with no grouping and is
+// only valid until the function returns, since a single buffer and machine are reused for every candidate. Returns an
+// error if any of the candidates is invalid.
+func ScoreAll(ciphertext string, candidates []Candidate, score func(plaintext []byte) float64) ([]float64, error) {
+	scores := make([]float64, len(candidates))
+
+	err := trialDecrypt(ciphertext, candidates, func(i int, plaintext []byte) {
+		scores[i] = score(plaintext)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
+
+// Decrypts the ciphertext with each candidate in turn, passing each plaintext to the result function along with the
+// index of its candidate.
+func trialDecrypt(ciphertext string, candidates []Candidate, result func(int, []byte)) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	letters := AppendLetters(nil, []byte(ciphertext))
+	buffer := make([]byte, 0, len(letters))
+
+	m, err := candidates[0].Key.Machine(candidates[0].Positions)
+	if err != nil {
+		return err
+	}
+
+	for i, candidate := range candidates {
+		err = m.Reset(candidate.Key, candidate.Positions)
+		if err != nil {
+			return err
+		}
+
+		buffer = m.AppendEncode(buffer[:0], letters)
+
+		result(i, buffer)
+	}
+
+	return nil
+}
@@ -0,0 +1,522 @@
+// Package bombe emulates the Turing-Welchman Bombe, which searched every rotor order and position for the settings
+// consistent with a menu, using the diagonal board to make the most of each guess at a plug.
+package bombe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/bits"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jtraynor/enigma"
+)
+
+// Every letter lit on a row of the diagonal board.
+const allLive = 1<<26 - 1
+
+// Bombe searches for the rotor orders and positions that are consistent with a menu. Like the real machine it assumes
+// every ring setting is 1, so a stop gives the rotor positions relative to the rings.
+type Bombe struct {
+	// Reflector is the name of the reflector to use. Defaults to B.
+	Reflector string
+	// Rotors are the names of the rotors to try in every order. Defaults to I - V.
+	Rotors []string
+	// Workers is the number of rotor orders searched at once. Defaults to GOMAXPROCS.
+	Workers int
+	// Checkpoint, if set, is the path of a file the rotor orders tried and the stops found so far are saved to at
+	// intervals and when the run stops, whether it finished, was cancelled or failed.
+	Checkpoint string
+	// Interval is how often the checkpoint is saved. Defaults to a minute.
+	Interval time.Duration
+	// Resume carries on the run from the checkpoint, if the file exists, rather than starting again.
+	Resume bool
+}
+
+// Stop is a rotor order and position at which the bombe stopped, along with the plug it suggests for the test letter.
+type Stop struct {
+	Rotors    [3]string
+	Positions string
+	// TestLetter is plugged to Stecker. They are the same letter if the test letter has no plug.
+	TestLetter rune
+	Stecker    rune
+}
+
+// String returns the stop in a readable form. e.g. "I-II-III ABC A=Z".
+func (s Stop) String() string {
+	return fmt.Sprintf("%s %s %c=%c", strings.Join(s.Rotors[:], "-"), s.Positions, s.TestLetter, s.Stecker)
+}
+
+// Solution is a stop confirmed by the checking machine, along with the plugs it implies and the decrypted message.
+type Solution struct {
+	Stop
+	// Key is the key the stop implies. Only the plugs of letters in the menu are known.
+	Key enigma.Key
+	// Plaintext is the whole ciphertext decrypted with the key from the positions of the stop.
+	Plaintext string
+}
+
+// Run tries every order of the rotors at every position against the menu and returns the stops, in order of rotor
+// order and then position. If the context is cancelled the run stops early, returning the stops found so far along
+// with the context's error. Returns an error if the menu has no links, a link is not between two letters within the
+// ciphertext, the bombe uses a reflector or rotors that do not exist, the checkpoint being resumed is for a different
+// run or the checkpoint could not be saved.
+func (b Bombe) Run(ctx context.Context, menu Menu) ([]Stop, error) {
+	if len(menu.Links) == 0 {
+		return nil, fmt.Errorf("menu has no links")
+	}
+
+	err := menu.check()
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := b.orders()
+	if err != nil {
+		return nil, err
+	}
+
+	progress := newCheckpoint(b.reflector(), orders, menu)
+
+	if b.Resume && len(b.Checkpoint) > 0 {
+		saved := checkpoint{}
+
+		err := enigma.LoadCheckpoint(b.Checkpoint, &saved)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// Nothing has been saved yet, so the run starts from the beginning
+		case err != nil:
+			return nil, err
+		case !reflect.DeepEqual(saved.Run, progress.Run) || saved.Cursor < 0 || saved.Cursor > len(orders):
+			return nil, fmt.Errorf("checkpoint is for a different run: %s", b.Checkpoint)
+		default:
+			progress = saved
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := b.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	type result struct {
+		job   int
+		stops []Stop
+	}
+
+	jobs := make(chan int)
+	results := make(chan result)
+
+	go func() {
+		defer close(jobs)
+
+		for job := progress.Cursor; job < len(orders) && ctx.Err() == nil; job++ {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				results <- result{job: job, stops: b.runOrder(menu, orders[job])}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Rotor orders finish out of order, so those after the cursor wait here until every order before them has finished
+	finished := map[int][]Stop{}
+
+	saved := time.Now()
+	var failed error
+
+	for r := range results {
+		finished[r.job] = r.stops
+		progress.advance(finished)
+
+		if len(b.Checkpoint) > 0 && failed == nil && time.Since(saved) >= b.interval() {
+			failed = enigma.SaveCheckpoint(b.Checkpoint, progress)
+			if failed != nil {
+				cancel()
+			}
+
+			saved = time.Now()
+		}
+	}
+
+	if len(b.Checkpoint) > 0 && failed == nil {
+		failed = enigma.SaveCheckpoint(b.Checkpoint, progress)
+	}
+
+	stops := progress.Stops
+	for job := progress.Cursor; job < len(orders); job++ {
+		stops = append(stops, finished[job]...)
+	}
+
+	if failed != nil {
+		return stops, failed
+	}
+
+	return stops, ctx.Err()
+}
+
+// Returns how often the checkpoint is saved.
+func (b Bombe) interval() time.Duration {
+	if b.Interval <= 0 {
+		return time.Minute
+	}
+
+	return b.Interval
+}
+
+// Check emulates the checking machine. It follows the stop through the menu to find the plugs of every letter
+// connected to the test letter, works out what it can of the plugs for the rest of the menu, then decrypts the
+// ciphertext with them to confirm that the crib comes out. Returns the solution and true if the stop is confirmed, or
+// false if it was a false stop or the stop or menu are invalid.
+func (b Bombe) Check(menu Menu, stop Stop) (Solution, bool) {
+	if menu.check() != nil || !isLetter(stop.TestLetter) || !isLetter(stop.Stecker) {
+		return Solution{}, false
+	}
+
+	s, err := newScrambler(b.reflector(), stop.Rotors)
+	if err != nil {
+		return Solution{}, false
+	}
+
+	start := enigma.PositionIndex(stop.Positions)
+	if start < 0 {
+		return Solution{}, false
+	}
+
+	sc := newScan(menu, s)
+	sc.setStart(start)
+	steckers := [26]int{}
+	for i := range steckers {
+		steckers[i] = -1
+	}
+
+	// Every letter connected to the test letter must have exactly one plug, otherwise the stop contradicts itself
+	sc.closure(int(stop.TestLetter-'A'), int(stop.Stecker-'A'), false)
+	if !sc.consistent(&steckers) {
+		return Solution{}, false
+	}
+
+	sc.assign(&steckers)
+
+	// The rest of the menu is made up of letters the test current never reached. Their plugs are taken wherever only
+	// one hypothesis fits with the plugs already found.
+	for _, link := range menu.Links {
+		letter := int(link.Plain - 'A')
+		if steckers[letter] >= 0 {
+			continue
+		}
+
+		found := -1
+		for value := 0; value < 26; value++ {
+			if value != letter && steckers[value] >= 0 {
+				continue
+			}
+
+			sc.closure(letter, value, false)
+			if !sc.consistent(&steckers) {
+				continue
+			}
+
+			if found >= 0 {
+				found = -1
+				break
+			}
+
+			found = value
+		}
+
+		if found >= 0 {
+			sc.closure(letter, found, false)
+			sc.assign(&steckers)
+		}
+	}
+
+	pairs := []string{}
+	for letter, value := range steckers {
+		if value > letter {
+			pairs = append(pairs, string([]rune{rune('A' + letter), rune('A' + value)}))
+		}
+	}
+
+	key, err := enigma.NewKey(b.reflector(), stop.Rotors, [3]int{1, 1, 1}, strings.Join(pairs, " "))
+	if err != nil {
+		return Solution{}, false
+	}
+
+	machine, err := key.Machine(stop.Positions)
+	if err != nil {
+		return Solution{}, false
+	}
+
+	plaintext := string(machine.AppendEncode(nil, []byte(menu.Ciphertext)))
+
+	// Every link with both its letters plugged must give back its crib letter
+	for _, link := range menu.Links {
+		if steckers[link.Plain-'A'] >= 0 && rune(plaintext[link.Position]) != link.Plain {
+			return Solution{}, false
+		}
+	}
+
+	return Solution{Stop: stop, Key: key, Plaintext: plaintext}, true
+}
+
+// Returns the name of the reflector to use.
+func (b Bombe) reflector() string {
+	if len(b.Reflector) == 0 {
+		return "B"
+	}
+
+	return b.Reflector
+}
+
+// Returns every order of 3 different rotors, from left to right.
+func (b Bombe) orders() ([][3]string, error) {
+	rotors := b.Rotors
+	if len(rotors) == 0 {
+		rotors = []string{"I", "II", "III", "IV", "V"}
+	}
+
+	orders, err := enigma.RotorOrders(rotors)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the reflector up front rather than in every worker
+	_, err = enigma.NewKey(b.reflector(), orders[0], [3]int{1, 1, 1}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// Tries every position of a single rotor order against the menu.
+func (b Bombe) runOrder(menu Menu, rotors [3]string) []Stop {
+	s, err := newScrambler(b.reflector(), rotors)
+	if err != nil {
+		return nil
+	}
+
+	sc := newScan(menu, s)
+	test := int(menu.TestLetter() - 'A')
+
+	stops := []Stop{}
+
+	for start := 0; start < enigma.PositionCount; start++ {
+		sc.setStart(start)
+
+		for _, stecker := range sc.test(test) {
+			stops = append(stops, Stop{
+				Rotors:     rotors,
+				Positions:  enigma.PositionLetters(start),
+				TestLetter: rune('A' + test),
+				Stecker:    rune('A' + stecker),
+			})
+		}
+	}
+
+	return stops
+}
+
+// Returns the unplugged enigma for a single rotor order, worked out in advance for every position.
+func newScrambler(reflector string, rotors [3]string) (*enigma.Scrambler, error) {
+	key, err := enigma.NewKey(reflector, rotors, [3]int{1, 1, 1}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Scrambler()
+}
+
+// A wire of the diagonal board, standing for the hypothesis that letter is plugged to value.
+type wire struct {
+	letter int
+	value  int
+}
+
+// An edge of the menu from one letter to another through the scrambler of a link.
+type edge struct {
+	to   int
+	link int
+}
+
+// Applies the test current to the menu with the scramblers at a given start position. Holds the live wires of the
+// diagonal board as a bit set of values for each letter.
+type scan struct {
+	scrambler *enigma.Scrambler
+	links     []Link
+	edges     [26][]edge
+	tables    []*[26]uint8
+	states    []int
+	live      [26]uint32
+	queue     []wire
+}
+
+func newScan(menu Menu, s *enigma.Scrambler) *scan {
+	sc := &scan{
+		scrambler: s,
+		links:     menu.Links,
+		edges:     menu.edges(),
+		tables:    make([]*[26]uint8, len(menu.Links)),
+	}
+
+	last := 0
+	for _, link := range menu.Links {
+		last = max(last, link.Position)
+	}
+
+	sc.states = make([]int, last+1)
+
+	return sc
+}
+
+// Sets every scrambler to the position it reaches from the start position by the time its letter is encoded.
+func (sc *scan) setStart(start int) {
+	state := start
+	for i := range sc.states {
+		state = sc.scrambler.Next(state)
+		sc.states[i] = state
+	}
+
+	for i, link := range sc.links {
+		sc.tables[i] = sc.scrambler.Table(sc.states[link.Position])
+	}
+}
+
+// Applies the test current to the test letter and returns every plug for it that survives. Most positions light every
+// wire of the test letter at once and have none.
+func (sc *scan) test(test int) []int {
+	live := sc.closure(test, 0, true)
+	if live == allLive {
+		return nil
+	}
+
+	steckers := []int{}
+	if live&(live-1) == 0 {
+		steckers = append(steckers, bits.TrailingZeros32(live))
+	}
+
+	// Each unlit wire of the test letter is a different hypothesis, which lights its own separate set of wires
+	covered := live
+	for value := 0; value < 26; value++ {
+		if covered&(1<<value) != 0 {
+			continue
+		}
+
+		row := sc.closure(test, value, true)
+		covered |= row
+
+		if row == 1<<value {
+			steckers = append(steckers, value)
+		}
+	}
+
+	sort.Ints(steckers)
+
+	return steckers
+}
+
+// Lights the wire for letter being plugged to value and follows the current through the menu and the diagonal board.
+// Returns the live wires of the letter, stopping early if they all light up and early is set.
+func (sc *scan) closure(letter, value int, early bool) uint32 {
+	sc.live = [26]uint32{}
+	sc.queue = sc.queue[:0]
+
+	sc.light(letter, value)
+
+	for len(sc.queue) > 0 {
+		w := sc.queue[len(sc.queue)-1]
+		sc.queue = sc.queue[:len(sc.queue)-1]
+
+		for _, e := range sc.edges[w.letter] {
+			sc.light(e.to, int(sc.tables[e.link][w.value]))
+		}
+
+		// The diagonal board joins the hypothesis A=B to B=A
+		sc.light(w.value, w.letter)
+
+		if early && sc.live[letter] == allLive {
+			break
+		}
+	}
+
+	return sc.live[letter]
+}
+
+// Returns true if every letter lit by the last closure has a single plug that does not contradict the plugs found.
+func (sc *scan) consistent(steckers *[26]int) bool {
+	for letter, live := range sc.live {
+		if live == 0 {
+			continue
+		}
+
+		if live&(live-1) != 0 {
+			return false
+		}
+
+		value := bits.TrailingZeros32(live)
+		if steckers[letter] >= 0 && steckers[letter] != value {
+			return false
+		}
+
+		// A letter can only be plugged to one other letter
+		if steckers[value] >= 0 && steckers[value] != letter {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Adds the plugs lit by the last closure to the plugs found.
+func (sc *scan) assign(steckers *[26]int) {
+	for letter, live := range sc.live {
+		if live != 0 {
+			steckers[letter] = bits.TrailingZeros32(live)
+		}
+	}
+}
+
+// Lights a wire, queueing it to be followed if it was not already live.
+func (sc *scan) light(letter, value int) {
+	bit := uint32(1) << value
+	if sc.live[letter]&bit != 0 {
+		return
+	}
+
+	sc.live[letter] |= bit
+	sc.queue = append(sc.queue, wire{letter: letter, value: value})
+}
+
+// Returns true if the rune is one of the letters A - Z.
+func isLetter(r rune) bool {
+	return r >= 'A' && r <= 'Z'
+}
@@ -0,0 +1,401 @@
+package bombe_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtraynor/enigma"
+	"github.com/jtraynor/enigma/bombe"
+)
+
+const (
+	testCrib      = "WETTERVORHERSAGEBISKAYA"
+	testPlaintext = "WETTERVORHERSAGEBISKAYAXKEINEBESONDERENVORKOMMNISSE"
+	testPositions = "QEV"
+)
+
+var testRotors = [3]string{"II", "V", "III"}
+
+// Returns a message encoded with a known key, for the bombe to find again.
+func testCiphertext(t *testing.T) (enigma.Key, string) {
+	key, err := enigma.NewKey("B", testRotors, [3]int{1, 1, 1}, "AR BY CU DH EQ FS GL KT")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	machine, err := key.Machine(testPositions)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	return key, string(machine.AppendEncode(nil, []byte(testPlaintext)))
+}
+
+var newMenuTests = map[string]struct {
+	crib       string
+	ciphertext string
+	offset     int
+}{
+	"Empty Crib": {
+		crib:       " ",
+		ciphertext: "ABCDE",
+		offset:     0,
+	},
+	"Negative Offset": {
+		crib:       "BC",
+		ciphertext: "ABCDE",
+		offset:     -1,
+	},
+	"Past The End": {
+		crib:       "BCD",
+		ciphertext: "ABCDE",
+		offset:     3,
+	},
+	"Encodes To Itself": {
+		crib:       "XCX",
+		ciphertext: "ABCDE",
+		offset:     1,
+	},
+}
+
+func TestNewMenuInvalid(t *testing.T) {
+	for name, tc := range newMenuTests {
+		t.Run(name, func(t *testing.T) {
+			_, err := bombe.NewMenu(tc.crib, tc.ciphertext, tc.offset)
+			if err == nil {
+				t.Errorf("Failed %s. Expected an error.", name)
+			}
+		})
+	}
+}
+
+func TestNewMenu(t *testing.T) {
+	menu, err := bombe.NewMenu("we-tt", "zz ABCDE", 2)
+	if err != nil {
+		t.Fatalf("Failed menu. Error: %v.", err)
+	}
+
+	expected := []bombe.Link{{'W', 'A', 2}, {'E', 'B', 3}, {'T', 'C', 4}, {'T', 'D', 5}}
+	if len(menu.Links) != len(expected) {
+		t.Fatalf("Failed links.\nExpected: %v.\nResult:   %v.", expected, menu.Links)
+	}
+
+	for i := range expected {
+		if menu.Links[i] != expected[i] {
+			t.Errorf("Failed link %d.\nExpected: %v.\nResult:   %v.", i, expected[i], menu.Links[i])
+		}
+	}
+
+	if menu.TestLetter() != 'T' {
+		t.Errorf("Failed test letter.\nExpected: T.\nResult:   %c.", menu.TestLetter())
+	}
+}
+
+func TestMenuGraph(t *testing.T) {
+	menu, err := bombe.NewMenu("ABCXY", "BCAYZ", 0)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	closures := menu.Closures()
+	expectedLoop := []bombe.Link{{'B', 'C', 1}, {'C', 'A', 2}, {'A', 'B', 0}}
+
+	if len(closures) != 1 || len(closures[0]) != len(expectedLoop) {
+		t.Fatalf("Failed closures.\nExpected: %v.\nResult:   %v.", [][]bombe.Link{expectedLoop}, closures)
+	}
+
+	for i := range expectedLoop {
+		if closures[0][i] != expectedLoop[i] {
+			t.Errorf("Failed closures.\nExpected: %v.\nResult:   %v.", expectedLoop, closures[0])
+		}
+	}
+
+	components := menu.Components()
+	if len(components) != 2 || len(components[0].Links) != 3 || len(components[1].Links) != 2 {
+		t.Errorf("Failed components. Expected 3 links then 2 links, got %v.", components)
+	}
+
+	best := menu.Best()
+	if best.String() != components[0].String() {
+		t.Errorf("Failed best.\nExpected: %s.\nResult:   %s.", components[0], best)
+	}
+
+	if menu.ExpectedStops() != 676 {
+		t.Errorf("Failed expected stops.\nExpected: 676.\nResult:   %v.", menu.ExpectedStops())
+	}
+
+	expected := "A: B(0) C(2)\nB: A(0) C(1)\nC: B(1) A(2)\nX: Y(3)\nY: X(3) Z(4)\nZ: Y(4)\n"
+	if menu.String() != expected {
+		t.Errorf("Failed text.\nExpected: %s.\nResult:   %s.", expected, menu.String())
+	}
+
+	expected = "graph menu {\n\tA -- B [label=\"0\"];\n\tB -- C [label=\"1\"];\n\tC -- A [label=\"2\"];\n" +
+		"\tX -- Y [label=\"3\"];\n\tY -- Z [label=\"4\"];\n}\n"
+	if menu.DOT() != expected {
+		t.Errorf("Failed DOT.\nExpected: %s.\nResult:   %s.", expected, menu.DOT())
+	}
+}
+
+func TestMenuInvalidLinks(t *testing.T) {
+	menu, err := bombe.NewMenu("ABCXY", "BCAYZ", 0)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	invalid := menu
+	invalid.Links = append([]bombe.Link{{'a', 'b', 5}, {'A', '1', 6}, {'!', 'C', 7}}, menu.Links...)
+
+	// The links that are not between two letters are left out rather than indexed
+	if invalid.TestLetter() != menu.TestLetter() {
+		t.Errorf("Failed test letter.\nExpected: %c.\nResult:   %c.", menu.TestLetter(), invalid.TestLetter())
+	}
+
+	if len(invalid.Closures()) != len(menu.Closures()) || len(invalid.Components()) != len(menu.Components()) {
+		t.Errorf("Failed closures.\nExpected: %v.\nResult:   %v.", menu.Closures(), invalid.Closures())
+	}
+
+	if invalid.ExpectedStops() != menu.ExpectedStops() {
+		t.Errorf("Failed expected stops.\nExpected: %v.\nResult:   %v.", menu.ExpectedStops(), invalid.ExpectedStops())
+	}
+
+	if invalid.String() != menu.String() {
+		t.Errorf("Failed text.\nExpected: %s.\nResult:   %s.", menu.String(), invalid.String())
+	}
+}
+
+func TestPlaceCribs(t *testing.T) {
+	menus := bombe.PlaceCribs("BCAXB", "ABC", "XY", "")
+
+	expected := []string{"ABC 0", "ABC 1", "XY 2", "XY 0", "XY 1"}
+
+	result := []string{}
+	for _, menu := range menus {
+		result = append(result, fmt.Sprintf("%s %d", menu.Crib, menu.Offset))
+	}
+
+	if strings.Join(result, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Failed placements.\nExpected: %v.\nResult:   %v.", expected, result)
+	}
+}
+
+func TestBombe(t *testing.T) {
+	key, ciphertext := testCiphertext(t)
+
+	menu, err := bombe.NewMenu(testCrib, ciphertext, 0)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	b := bombe.Bombe{Rotors: []string{"II", "III", "V"}}
+
+	stops, err := b.Run(context.Background(), menu.Best())
+	if err != nil {
+		t.Fatalf("Failed run. Error: %v.", err)
+	}
+
+	solutions := []bombe.Solution{}
+	for _, stop := range stops {
+		solution, ok := b.Check(menu, stop)
+		if ok {
+			solutions = append(solutions, solution)
+		}
+	}
+
+	if len(solutions) != 1 {
+		t.Fatalf("Failed solutions. Expected 1 of %d stops to check out, got %v.", len(stops), solutions)
+	}
+
+	solution := solutions[0]
+
+	if solution.Rotors != testRotors || solution.Positions != testPositions {
+		t.Errorf("Failed stop.\nExpected: %v %s.\nResult:   %v %s.", testRotors, testPositions, solution.Rotors,
+			solution.Positions)
+	}
+
+	if solution.Plaintext != testPlaintext {
+		t.Errorf("Failed plaintext.\nExpected: %s.\nResult:   %s.", testPlaintext, solution.Plaintext)
+	}
+
+	// Only plugs of letters in the menu can be found, and they must all be part of the real key
+	for _, pair := range strings.Fields(solution.Key.Plugs()) {
+		if !strings.Contains(key.Plugs(), pair) {
+			t.Errorf("Failed plugs.\nExpected: %s.\nResult:   %s.", key.Plugs(), solution.Key.Plugs())
+		}
+	}
+}
+
+func TestBombeCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	menu, err := bombe.NewMenu("BC", "ABCDE", 0)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	_, err = bombe.Bombe{}.Run(ctx, menu)
+	if err != context.Canceled {
+		t.Errorf("Failed cancel.\nExpected: %v.\nResult:   %v.", context.Canceled, err)
+	}
+}
+
+func TestBombeCheckpoint(t *testing.T) {
+	_, ciphertext := testCiphertext(t)
+
+	menu, err := bombe.NewMenu(testCrib, ciphertext, 0)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	b := bombe.Bombe{
+		Rotors:     []string{"II", "III", "V"},
+		Workers:    2,
+		Checkpoint: filepath.Join(t.TempDir(), "bombe.json"),
+		Interval:   time.Nanosecond,
+	}
+
+	expected, err := b.Run(context.Background(), menu.Best())
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	orders, err := enigma.RotorOrders(b.Rotors)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	// Wind the checkpoint back to half way, leaving out the stops of the first rotor order so that it shows whether
+	// the orders before the cursor are tried again
+	data, err := os.ReadFile(b.Checkpoint)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	saved := map[string]any{}
+
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	kept := []bombe.Stop{}
+	resumed := []bombe.Stop{}
+	for _, stop := range expected {
+		switch stop.Rotors {
+		case orders[0]:
+		case orders[1], orders[2]:
+			kept = append(kept, stop)
+			resumed = append(resumed, stop)
+		default:
+			resumed = append(resumed, stop)
+		}
+	}
+
+	saved["cursor"] = 3
+	saved["stops"] = kept
+
+	data, err = json.Marshal(saved)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = os.WriteFile(b.Checkpoint, data, 0o644)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	b.Resume = true
+
+	stops, err := b.Run(context.Background(), menu.Best())
+	if err != nil {
+		t.Fatalf("Failed resume. Error: %v.", err)
+	}
+
+	if !reflect.DeepEqual(stops, resumed) {
+		t.Errorf("Failed resume.\nExpected: %v.\nResult:   %v.", resumed, stops)
+	}
+
+	// A finished checkpoint has nothing left to try
+	stops, err = b.Run(context.Background(), menu.Best())
+	if err != nil || !reflect.DeepEqual(stops, resumed) {
+		t.Errorf("Failed finished resume.\nExpected: %v.\nResult:   %v, %v.", resumed, stops, err)
+	}
+
+	_, err = b.Run(context.Background(), menu)
+	if err == nil {
+		t.Errorf("Failed resume of a different run. Expected an error.")
+	}
+}
+
+func TestBombeInvalid(t *testing.T) {
+	menu, err := bombe.NewMenu("BC", "ABCDE", 0)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	for name, b := range map[string]bombe.Bombe{
+		"Reflector":   {Reflector: "Z"},
+		"Rotor":       {Rotors: []string{"I", "II", "IX"}},
+		"Rotor Count": {Rotors: []string{"I", "II"}},
+	} {
+		_, err := b.Run(context.Background(), menu)
+		if err == nil {
+			t.Errorf("Failed %s. Expected an error.", name)
+		}
+	}
+}
+
+func TestCheckInvalid(t *testing.T) {
+	_, ciphertext := testCiphertext(t)
+
+	menu, err := bombe.NewMenu(testCrib, ciphertext, 0)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	stop := bombe.Stop{Rotors: testRotors, Positions: testPositions, TestLetter: 'E', Stecker: 'E'}
+
+	outside := menu
+	outside.Links = append([]bombe.Link{{'A', 'B', len(ciphertext)}}, menu.Links...)
+
+	negative := menu
+	negative.Links = append([]bombe.Link{{'A', 'B', -1}}, menu.Links...)
+
+	notLetter := menu
+	notLetter.Links = append([]bombe.Link{{'a', 'B', 0}}, menu.Links...)
+
+	// The position is within the ciphertext as written but past its letters
+	spaced := bombe.Menu{Ciphertext: "AB CD EF GH", Links: []bombe.Link{{'B', 'A', 10}}}
+
+	for name, tc := range map[string]struct {
+		menu bombe.Menu
+		stop bombe.Stop
+	}{
+		"Zero Stop":         {menu, bombe.Stop{}},
+		"Positions":         {menu, bombe.Stop{Rotors: testRotors, Positions: "A1", TestLetter: 'E', Stecker: 'E'}},
+		"Test Letter":       {menu, bombe.Stop{Rotors: testRotors, Positions: testPositions, TestLetter: '1'}},
+		"Past Ciphertext":   {outside, stop},
+		"Negative Position": {negative, stop},
+		"Link Letter":       {notLetter, stop},
+		"Spaced Ciphertext": {spaced, stop},
+	} {
+		_, ok := bombe.Bombe{}.Check(tc.menu, tc.stop)
+		if ok {
+			t.Errorf("Failed %s. Expected the stop not to check out.", name)
+		}
+	}
+
+	for name, m := range map[string]bombe.Menu{"Negative Position": negative, "Spaced Ciphertext": spaced} {
+		_, err = bombe.Bombe{}.Run(context.Background(), m)
+		if err == nil {
+			t.Errorf("Failed run with %s. Expected an error.", name)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package bombe
+
+import (
+	"fmt"
+)
+
+// The progress of a run saved to a file, so that it can be resumed after it stops. Every rotor order before the
+// cursor has been tried, and the stops are those they found.
+type checkpoint struct {
+	Run    checkpointRun `json:"run"`
+	Cursor int           `json:"cursor"`
+	Stops  []Stop        `json:"stops"`
+}
+
+// The run a checkpoint was saved from, so that it is never resumed by a different run.
+type checkpointRun struct {
+	Reflector  string      `json:"reflector"`
+	Orders     [][3]string `json:"orders"`
+	Ciphertext string      `json:"ciphertext"`
+	Links      []string    `json:"links"`
+}
+
+// Returns an empty checkpoint for running the rotor orders against the menu.
+func newCheckpoint(reflector string, orders [][3]string, menu Menu) checkpoint {
+	c := checkpoint{
+		Run: checkpointRun{
+			Reflector:  reflector,
+			Orders:     orders,
+			Ciphertext: menu.Ciphertext,
+			Links:      []string{},
+		},
+		Stops: []Stop{},
+	}
+
+	for _, link := range menu.Links {
+		c.Run.Links = append(c.Run.Links, fmt.Sprintf("%c%c%d", link.Plain, link.Cipher, link.Position))
+	}
+
+	return c
+}
+
+// Moves the cursor past every rotor order that has finished in order, saving their stops. The orders moved past are
+// removed from those finished.
+func (c *checkpoint) advance(finished map[int][]Stop) {
+	for {
+		stops, check := finished[c.Cursor]
+		if !check {
+			return
+		}
+
+		c.Stops = append(c.Stops, stops...)
+
+		delete(finished, c.Cursor)
+		c.Cursor++
+	}
+}
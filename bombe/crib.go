@@ -0,0 +1,56 @@
+package bombe
+
+import (
+	"sort"
+
+	"github.com/jtraynor/enigma"
+)
+
+// PlaceCribs slides each crib along the ciphertext and returns a menu for every offset at which no letter of the crib
+// lines up with the same letter of the ciphertext, as the enigma never encodes a letter to itself. The menus are
+// ranked with the fewest expected false stops for their best connected part first, then the most links.
+func PlaceCribs(ciphertext string, cribs ...string) []Menu {
+	menus := []Menu{}
+	stops := []float64{}
+	links := []int{}
+
+	length := len(enigma.AppendLetters(nil, []byte(ciphertext)))
+
+	for _, crib := range cribs {
+		size := len(enigma.AppendLetters(nil, []byte(crib)))
+
+		for offset := 0; size > 0 && offset+size <= length; offset++ {
+			menu, err := NewMenu(crib, ciphertext, offset)
+			if err != nil {
+				continue
+			}
+
+			best := menu.Best()
+
+			menus = append(menus, menu)
+			stops = append(stops, best.ExpectedStops())
+			links = append(links, len(best.Links))
+		}
+	}
+
+	order := make([]int, len(menus))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if stops[a] != stops[b] {
+			return stops[a] < stops[b]
+		}
+
+		return links[a] > links[b]
+	})
+
+	ranked := make([]Menu, len(menus))
+	for i, index := range order {
+		ranked[i] = menus[index]
+	}
+
+	return ranked
+}
@@ -0,0 +1,334 @@
+package bombe
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jtraynor/enigma"
+)
+
+// Link joins a letter of the crib to the letter of the ciphertext it was encoded as. On the bombe each link is a
+// scrambler set to the position of the letter in the message.
+type Link struct {
+	Plain    rune
+	Cipher   rune
+	Position int
+}
+
+// Menu is the set of links made by laying a crib against the ciphertext at a given offset. A menu built by hand may
+// have links that are not between two letters A - Z. The methods of the menu leave these out, while Bombe.Run and
+// Bombe.Check reject them.
+type Menu struct {
+	// Crib is the guessed plaintext and Ciphertext the intercepted message, both as upper case letters only.
+	Crib       string
+	Ciphertext string
+	// Offset is the index of the ciphertext letter the crib starts at, counting from 0.
+	Offset int
+	Links  []Link
+}
+
+// NewMenu lays the crib against the ciphertext starting at the offset and returns the links between them. Anything
+// other than letters is ignored in both. Returns an error if the crib runs past the end of the ciphertext, or if any
+// letter of the crib lines up with the same letter in the ciphertext, which the enigma can never do.
+func NewMenu(crib, ciphertext string, offset int) (Menu, error) {
+	menu := Menu{
+		Crib:       string(enigma.AppendLetters(nil, []byte(crib))),
+		Ciphertext: string(enigma.AppendLetters(nil, []byte(ciphertext))),
+		Offset:     offset,
+	}
+
+	if len(menu.Crib) == 0 {
+		return Menu{}, fmt.Errorf("empty crib: %s", crib)
+	}
+
+	if offset < 0 || offset+len(menu.Crib) > len(menu.Ciphertext) {
+		return Menu{}, fmt.Errorf("crib does not fit at offset %d: %s", offset, crib)
+	}
+
+	for i := range menu.Crib {
+		plain := rune(menu.Crib[i])
+		cipher := rune(menu.Ciphertext[offset+i])
+
+		if plain == cipher {
+			return Menu{}, fmt.Errorf("crib letter %c encodes to itself at position %d", plain, offset+i)
+		}
+
+		menu.Links = append(menu.Links, Link{Plain: plain, Cipher: cipher, Position: offset + i})
+	}
+
+	return menu, nil
+}
+
+// TestLetter returns the letter with the most links, which is where the bombe applies its test current. Ties go to the
+// letter earliest in the alphabet.
+func (m Menu) TestLetter() rune {
+	counts := [26]int{}
+	for _, link := range m.Links {
+		if !link.valid() {
+			continue
+		}
+
+		counts[link.Plain-'A']++
+		counts[link.Cipher-'A']++
+	}
+
+	best := 0
+	for i, count := range counts {
+		if count > counts[best] {
+			best = i
+		}
+	}
+
+	return rune('A' + best)
+}
+
+// Closures returns the loops of the menu, each as the links followed from a letter back to itself. Every loop in the
+// menu can be made by combining these, and each one cuts the number of false stops by a factor of about 26.
+func (m Menu) Closures() [][]Link {
+	closures := [][]Link{}
+
+	for _, component := range m.components() {
+		closures = append(closures, component.closures...)
+	}
+
+	return closures
+}
+
+// Components returns the parts of the menu that are not linked to each other, each as a menu of its own. The best
+// connected come first: those with the most closures, then the most links.
+func (m Menu) Components() []Menu {
+	components := m.components()
+
+	menus := make([]Menu, len(components))
+	for i, component := range components {
+		menus[i] = m
+		menus[i].Links = component.links
+	}
+
+	return menus
+}
+
+// Best returns the best connected part of the menu, which is the part worth running on the bombe.
+func (m Menu) Best() Menu {
+	components := m.Components()
+	if len(components) == 0 {
+		return m
+	}
+
+	return components[0]
+}
+
+// ExpectedStops returns roughly how many false stops the bombe will make for each rotor order. Only the part of the
+// menu linked to the test letter counts, as the test current never reaches the rest.
+func (m Menu) ExpectedStops() float64 {
+	closures := 0
+
+	test := m.TestLetter()
+	for _, component := range m.components() {
+		if strings.ContainsRune(component.letters, test) {
+			closures = len(component.closures)
+		}
+	}
+
+	return enigma.PositionCount / math.Pow(26, float64(closures))
+}
+
+// String returns the menu as plain text, with a line for each letter listing the letters it links to and the positions
+// of the links. e.g. "E: A(3) T(4)".
+func (m Menu) String() string {
+	var result strings.Builder
+
+	for letter, edges := range m.edges() {
+		if len(edges) == 0 {
+			continue
+		}
+
+		result.WriteString(fmt.Sprintf("%c:", 'A'+letter))
+
+		for _, e := range edges {
+			result.WriteString(fmt.Sprintf(" %c(%d)", 'A'+e.to, m.Links[e.link].Position))
+		}
+
+		result.WriteString("\n")
+	}
+
+	return result.String()
+}
+
+// DOT returns the menu as an undirected graph in the DOT language, with a node for each letter and an edge labelled
+// with the position of each link.
+func (m Menu) DOT() string {
+	var result strings.Builder
+
+	result.WriteString("graph menu {\n")
+
+	for _, link := range m.Links {
+		result.WriteString(fmt.Sprintf("\t%c -- %c [label=\"%d\"];\n", link.Plain, link.Cipher, link.Position))
+	}
+
+	result.WriteString("}\n")
+
+	return result.String()
+}
+
+// A connected part of the menu, with its letters, links and a set of closures.
+type component struct {
+	letters  string
+	links    []Link
+	closures [][]Link
+}
+
+// Returns the links of each letter in the menu, as edges to the other letter of the link.
+func (m Menu) edges() [26][]edge {
+	edges := [26][]edge{}
+
+	for i, link := range m.Links {
+		if !link.valid() {
+			continue
+		}
+
+		plain := int(link.Plain - 'A')
+		cipher := int(link.Cipher - 'A')
+
+		edges[plain] = append(edges[plain], edge{to: cipher, link: i})
+		edges[cipher] = append(edges[cipher], edge{to: plain, link: i})
+	}
+
+	return edges
+}
+
+// Returns true if the link is between two letters A - Z.
+func (l Link) valid() bool {
+	return isLetter(l.Plain) && isLetter(l.Cipher)
+}
+
+// Returns an error if the ciphertext is not all letters A - Z, or a link is not between two letters A - Z or its
+// position is outside the ciphertext.
+func (m Menu) check() error {
+	for _, letter := range m.Ciphertext {
+		if !isLetter(letter) {
+			return fmt.Errorf("invalid ciphertext letter: %c", letter)
+		}
+	}
+
+	for _, link := range m.Links {
+		if !link.valid() {
+			return fmt.Errorf("invalid link: %c%c", link.Plain, link.Cipher)
+		}
+
+		if link.Position < 0 || link.Position >= len(m.Ciphertext) {
+			return fmt.Errorf("link outside the ciphertext at position %d", link.Position)
+		}
+	}
+
+	return nil
+}
+
+// Splits the menu into its connected parts, best connected first. A spanning tree is grown across each part and every
+// link left out of the tree closes a loop with it.
+func (m Menu) components() []component {
+	edges := m.edges()
+
+	// The link leading to each letter from its parent in the tree, or -1 for the root
+	parents := [26]int{}
+	depths := [26]int{}
+	visited := [26]bool{}
+	inTree := make([]bool, len(m.Links))
+
+	components := []component{}
+
+	for root := range edges {
+		if visited[root] || len(edges[root]) == 0 {
+			continue
+		}
+
+		c := component{}
+
+		visited[root] = true
+		parents[root] = -1
+
+		queue := []int{root}
+		for len(queue) > 0 {
+			letter := queue[0]
+			queue = queue[1:]
+
+			c.letters += string(rune('A' + letter))
+
+			for _, e := range edges[letter] {
+				if visited[e.to] {
+					continue
+				}
+
+				visited[e.to] = true
+				parents[e.to] = e.link
+				depths[e.to] = depths[letter] + 1
+				inTree[e.link] = true
+
+				queue = append(queue, e.to)
+			}
+		}
+
+		for i, link := range m.Links {
+			if !link.valid() || !strings.ContainsRune(c.letters, link.Plain) {
+				continue
+			}
+
+			c.links = append(c.links, link)
+
+			if !inTree[i] {
+				c.closures = append(c.closures, m.loop(i, parents, depths))
+			}
+		}
+
+		components = append(components, c)
+	}
+
+	sort.SliceStable(components, func(i, j int) bool {
+		if len(components[i].closures) != len(components[j].closures) {
+			return len(components[i].closures) > len(components[j].closures)
+		}
+
+		return len(components[i].links) > len(components[j].links)
+	})
+
+	return components
+}
+
+// Returns the loop made by adding a link to the spanning tree, starting with the link itself and following the tree
+// back round to where it started.
+func (m Menu) loop(link int, parents, depths [26]int) []Link {
+	from := int(m.Links[link].Plain - 'A')
+	to := int(m.Links[link].Cipher - 'A')
+
+	// Climb from both ends of the link until the paths meet
+	up := []Link{}
+	down := []Link{}
+
+	for from != to {
+		if depths[to] >= depths[from] {
+			up = append(up, m.Links[parents[to]])
+			to = m.other(parents[to], to)
+		} else {
+			down = append(down, m.Links[parents[from]])
+			from = m.other(parents[from], from)
+		}
+	}
+
+	loop := append([]Link{m.Links[link]}, up...)
+	for i := len(down) - 1; i >= 0; i-- {
+		loop = append(loop, down[i])
+	}
+
+	return loop
+}
+
+// Returns the letter at the other end of a link.
+func (m Menu) other(link, letter int) int {
+	if int(m.Links[link].Plain-'A') == letter {
+		return int(m.Links[link].Cipher - 'A')
+	}
+
+	return int(m.Links[link].Plain - 'A')
+}
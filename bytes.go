@@ -0,0 +1,61 @@
+package enigma
+
+// AppendEncode encodes each ASCII letter of src in turn and appends the upper case result to dst, returning the
+// extended slice. Bytes that are not letters are ignored and the output is not grouped. Nothing is allocated unless dst
+// needs to grow.
+func (e *Enigma) AppendEncode(dst, src []byte) []byte {
+	for _, b := range src {
+		letter := upperLetter(b)
+		if letter == 0 {
+			continue
+		}
+
+		dst = append(dst, byte(e.encodeLetter(rune(letter))))
+	}
+
+	return dst
+}
+
+// EncodeInPlace encodes each ASCII letter of buf in turn, replacing it with the result in the same case. Bytes that are
+// not letters are left unchanged.
+func (e *Enigma) EncodeInPlace(buf []byte) {
+	for i, b := range buf {
+		letter := upperLetter(b)
+		if letter == 0 {
+			continue
+		}
+
+		result := byte(e.encodeLetter(rune(letter)))
+		if b != letter {
+			result += 'a' - 'A'
+		}
+
+		buf[i] = result
+	}
+}
+
+// AppendLetters appends the upper case form of each ASCII letter of src to dst, ignoring any other bytes, and returns
+// the extended slice.
+func AppendLetters(dst, src []byte) []byte {
+	for _, b := range src {
+		letter := upperLetter(b)
+		if letter != 0 {
+			dst = append(dst, letter)
+		}
+	}
+
+	return dst
+}
+
+// Returns the upper case form of an ASCII letter, or 0 if the byte is not a letter.
+func upperLetter(b byte) byte {
+	if b >= 'a' && b <= 'z' {
+		return b - ('a' - 'A')
+	}
+
+	if b >= 'A' && b <= 'Z' {
+		return b
+	}
+
+	return 0
+}
@@ -0,0 +1,144 @@
+package enigma
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+)
+
+// The progress of a search saved to a file, so that it can be resumed after it stops. Every job before the cursor has
+// been tried, and the matches are those found by them.
+type checkpoint struct {
+	Search  checkpointSearch  `json:"search"`
+	Cursor  int               `json:"cursor"`
+	Matches []checkpointMatch `json:"matches"`
+}
+
+// The search a checkpoint was saved from, so that it is never resumed by a different search.
+type checkpointSearch struct {
+	Crib       string      `json:"crib"`
+	Ciphertext string      `json:"ciphertext"`
+	Offset     int         `json:"offset"`
+	Reflectors []string    `json:"reflectors"`
+	Orders     [][3]string `json:"orders"`
+	Rings      [3][2]int   `json:"rings"`
+	Plugs      string      `json:"plugs"`
+	Jobs       int         `json:"jobs"`
+}
+
+// A match saved in a checkpoint. The key is only there to be read, as the job is enough to work it out again.
+type checkpointMatch struct {
+	Job       int    `json:"job"`
+	Key       string `json:"key"`
+	Positions string `json:"positions"`
+}
+
+// Returns an empty checkpoint for searching the space for the crib.
+func (space searchSpace) checkpoint(plain, cipher []byte, offset int) checkpoint {
+	c := checkpoint{
+		Search: checkpointSearch{
+			Crib:       string(plain),
+			Ciphertext: string(cipher),
+			Offset:     offset,
+			Reflectors: space.reflectors,
+			Orders:     space.orders,
+			Plugs:      space.plugs,
+			Jobs:       space.jobs,
+		},
+		Matches: []checkpointMatch{},
+	}
+
+	for i, r := range space.rings {
+		c.Search.Rings[i] = [2]int{r.First, r.Last}
+	}
+
+	return c
+}
+
+// Returns the candidates of the matches saved in the checkpoint followed by those of the jobs after the cursor that
+// have finished, in the order of their jobs.
+func (space searchSpace) candidates(c checkpoint, finished map[int][]Candidate) []Candidate {
+	candidates := []Candidate{}
+	for _, match := range c.Matches {
+		candidates = append(candidates, Candidate{Key: space.key(match.Job), Positions: match.Positions})
+	}
+
+	jobs := []int{}
+	for job := range finished {
+		jobs = append(jobs, job)
+	}
+
+	sort.Ints(jobs)
+
+	for _, job := range jobs {
+		candidates = append(candidates, finished[job]...)
+	}
+
+	return candidates
+}
+
+// Moves the cursor past every job that has finished in order, saving their matches. The jobs moved past are removed
+// from those finished.
+func (c *checkpoint) advance(finished map[int][]Candidate) {
+	for {
+		matches, check := finished[c.Cursor]
+		if !check {
+			return
+		}
+
+		for _, match := range matches {
+			c.Matches = append(c.Matches, checkpointMatch{
+				Job:       c.Cursor,
+				Key:       match.Key.String(),
+				Positions: match.Positions,
+			})
+		}
+
+		delete(finished, c.Cursor)
+		c.Cursor++
+	}
+}
+
+// Writes the checkpoint to the file at the path.
+func (c checkpoint) save(path string) error {
+	return SaveCheckpoint(path, c)
+}
+
+// Reads a checkpoint from the file at the path.
+func loadCheckpoint(path string) (checkpoint, error) {
+	c := checkpoint{}
+
+	err := LoadCheckpoint(path, &c)
+	if err != nil {
+		return checkpoint{}, err
+	}
+
+	return c, nil
+}
+
+// SaveCheckpoint writes the progress of a long search to the file at the path as JSON. It is written to a temporary
+// file first and then renamed, so that a crash part way through never leaves a file that is only half written.
+func SaveCheckpoint(path string, progress any) error {
+	data, err := json.MarshalIndent(progress, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path+".tmp", append(data, '\n'), 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// LoadCheckpoint reads the progress of a long search saved by SaveCheckpoint from the file at the path into progress.
+// The error wraps fs.ErrNotExist if nothing has been saved yet.
+func LoadCheckpoint(path string, progress any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, progress)
+}
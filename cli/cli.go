@@ -1,153 +1,356 @@
-package main
-
-import (
-	"flag"
-	"fmt"
-	"os"
-	"strconv"
-	"strings"
-	"unicode"
-
-	"github.com/jtraynor/enigma"
-)
-
-func main() {
-	flag.Usage = func() {
-		fmt.Fprint(os.Stderr, "Enigma cipher machine emulator.\n\nUsage:\n enigma [OPTIONS] [MESSAGE]\n\nOptions:\n")
-		flag.PrintDefaults()
-	}
-
-	l := flag.String("l", "III", "The rotor to be used in the left positon. Roman numerals between I - VII.")
-	lr := flag.String("lr", "1", "The ring setting of the left rotor. A number between 1 - 26.")
-	ls := flag.String("ls", "A", "The start positon of the left rotor. A letter between A - Z.")
-
-	m := flag.String("m", "II", "The rotor to be used in the middle positon. Roman numerals between I - VII.")
-	mr := flag.String("mr", "1", "The ring setting of the middle rotor. A number between 1 - 26.")
-	ms := flag.String("ms", "A", "The start positon of the middle rotor. A letter between A - Z.")
-
-	r := flag.String("r", "I", "The rotor to be used in the right positon. Roman numerals between I - VII.")
-	rr := flag.String("rr", "1", "The ring setting of the right rotor. A number between 1 - 26.")
-	rs := flag.String("rs", "A", "The start positon of the right rotor. A letter between A - Z.")
-
-	ref := flag.String("ref", "B", "The reflector to be used. Either B or C.")
-
-	p := flag.String("p", "", "A comma seperated list of letter pairs. e.g. \"AB,CD,EF\".")
-
-	flag.Parse()
-
-	message := strings.Join(flag.Args(), " ")
-	if len(message) == 0 {
-		flag.Usage()
-		return
-	}
-
-	leftRotor := parseRotor("Left", *l)
-	leftRing := parseRing("Left", *lr)
-	leftStart := parseStart("Left", *ls)
-
-	middleRotor := parseRotor("Middle", *m)
-	middleRing := parseRing("Middle", *mr)
-	middleStart := parseStart("Middle", *ms)
-
-	rightRotor := parseRotor("Right", *r)
-	rightRing := parseRing("Right", *rr)
-	rightStart := parseStart("Right", *rs)
-
-	reflector := parseReflector(*ref)
-
-	plugs := parsePlugs(*p)
-
-	e := enigma.New()
-
-	err := e.SetRotor("left", leftRotor, leftRing, leftStart)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Left rotor \"%s\" should be a roman numeral between I - VII.\n", leftRotor)
-		os.Exit(1)
-	}
-
-	err = e.SetRotor("middle", middleRotor, middleRing, middleStart)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Middle rotor \"%s\" should be a roman numeral between I - VII.\n", middleRotor)
-		os.Exit(1)
-	}
-
-	err = e.SetRotor("right", rightRotor, rightRing, rightStart)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Right rotor \"%s\" should be a roman numeral between I - VII.\n", rightRotor)
-		os.Exit(1)
-	}
-
-	err = e.SetReflector(reflector)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Reflector \"%s\" should be either B or C.\n", reflector)
-		os.Exit(1)
-	}
-
-	err = e.AddPlugs(plugs)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Plugs \"%s\" should be a comma seperated list of letter pairs.\n", *p)
-		os.Exit(1)
-	}
-
-	fmt.Println(e.Encode(message))
-}
-
-func parseRotor(position, input string) string {
-	if len(input) == 0 {
-		switch position {
-		case "Left":
-			return "III"
-		case "Middle":
-			return "II"
-		case "Right":
-		default:
-			return "I"
-		}
-	}
-
-	return input
-}
-
-func parseRing(position, input string) int {
-	if len(input) == 0 {
-		return 1
-	}
-
-	ring, err := strconv.Atoi(input)
-	if err != nil || ring < 1 || ring > 26 {
-		fmt.Fprintf(os.Stderr, "%s rotor ring setting \"%s\" should be a number between 1 and 26.\n", position, input)
-		os.Exit(1)
-	}
-
-	return ring
-}
-
-func parseStart(position, input string) rune {
-	if len(input) == 0 {
-		return 'A'
-	}
-
-	start := unicode.ToUpper(rune(input[0]))
-	if start < 'A' || start > 'Z' {
-		fmt.Fprintf(os.Stderr, "%s rotor start position \"%s\" should be a letter between A - Z.\n", position, input)
-		os.Exit(1)
-	}
-
-	return start
-}
-
-func parseReflector(input string) string {
-	if len(input) == 0 {
-		return "B"
-	}
-
-	return input
-}
-
-func parsePlugs(input string) []string {
-	if len(input) == 0 {
-		return []string{}
-	}
-
-	return strings.Split(input, ",")
-}
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/jtraynor/enigma"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "crib":
+			crib(os.Args[2:])
+			return
+		case "score":
+			score(os.Args[2:])
+			return
+		case "search":
+			search(os.Args[2:])
+			return
+		}
+	}
+
+	rotors := listWheels(enigma.AvailableRotors())
+	reflectors := listWheels(enigma.AvailableReflectors())
+
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, "Enigma cipher machine emulator.\n\nUsage:\n enigma [OPTIONS] [MESSAGE]\n"+
+			" enigma crib -c CIPHERTEXT CRIB [CRIB...]\n enigma score [OPTIONS] TEXT [TEXT...]\n"+
+			" enigma search [OPTIONS] -c CIPHERTEXT CRIB\n\n"+
+			"Use - as the message to read it from standard input.\n\nOptions:\n")
+		flag.PrintDefaults()
+		fmt.Fprint(os.Stderr, "\nRotors:\n")
+		printWheels(enigma.AvailableRotors())
+		fmt.Fprint(os.Stderr, "\nReflectors:\n")
+		printWheels(enigma.AvailableReflectors())
+	}
+
+	l := flag.String("l", "III", "The rotor to be used in the left positon. One of "+rotors+".")
+	lr := flag.String("lr", "1", "The ring setting of the left rotor. A number between 1 - 26.")
+	ls := flag.String("ls", "A", "The start positon of the left rotor. A letter between A - Z.")
+
+	m := flag.String("m", "II", "The rotor to be used in the middle positon. One of "+rotors+".")
+	mr := flag.String("mr", "1", "The ring setting of the middle rotor. A number between 1 - 26.")
+	ms := flag.String("ms", "A", "The start positon of the middle rotor. A letter between A - Z.")
+
+	r := flag.String("r", "I", "The rotor to be used in the right positon. One of "+rotors+".")
+	rr := flag.String("rr", "1", "The ring setting of the right rotor. A number between 1 - 26.")
+	rs := flag.String("rs", "A", "The start positon of the right rotor. A letter between A - Z.")
+
+	ref := flag.String("ref", "B", "The reflector to be used. One of "+reflectors+".")
+
+	p := flag.String("p", "", "A comma seperated list of letter pairs. e.g. \"AB,CD,EF\".")
+
+	g := flag.String("german", "", "Apply the German plaintext conventions. Either in, before encoding, or out, after decoding.")
+	n := flag.String("numbers", "spell", "How numbers are written by -german in. Either spell or bracket.")
+
+	i := flag.String("input", "drop", "What to do with characters that are not letters. Either drop, pass, reject or translit.")
+	gs := flag.String("group", "5", "The number of letters in each group of the output. 0 disables grouping.")
+	w := flag.String("wrap", "0", "The number of groups on each line of the output. 0 never breaks the line.")
+	k := flag.Bool("keep", false, "Keep the case and spacing of the message instead of grouping the output.")
+
+	t := flag.Bool("trace", false, "Print the path of each letter through the machine instead of the result.")
+
+	flag.Parse()
+
+	message := strings.Join(flag.Args(), " ")
+	if len(message) == 0 {
+		flag.Usage()
+		return
+	}
+
+	leftRotor := parseRotor("Left", *l)
+	leftRing := parseRing("Left", *lr)
+	leftStart := parseStart("Left", *ls)
+
+	middleRotor := parseRotor("Middle", *m)
+	middleRing := parseRing("Middle", *mr)
+	middleStart := parseStart("Middle", *ms)
+
+	rightRotor := parseRotor("Right", *r)
+	rightRing := parseRing("Right", *rr)
+	rightStart := parseStart("Right", *rs)
+
+	reflector := parseReflector(*ref)
+
+	plugs := parsePlugs(*p)
+
+	german := parseGerman(*g)
+	numbers := parseNumbers(*n)
+
+	options := enigma.EncodeOptions{
+		Input:          parseInput(*i),
+		Numbers:        numbers,
+		GroupSize:      parseCount("Group size", *gs, 5),
+		LineGroups:     parseCount("Wrap", *w, 0),
+		PreserveLayout: *k,
+	}
+
+	e := enigma.New()
+
+	err := e.SetRotor("left", leftRotor, leftRing, leftStart)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Left rotor \"%s\" should be one of %s.\n", leftRotor, rotors)
+		os.Exit(1)
+	}
+
+	err = e.SetRotor("middle", middleRotor, middleRing, middleStart)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Middle rotor \"%s\" should be one of %s.\n", middleRotor, rotors)
+		os.Exit(1)
+	}
+
+	err = e.SetRotor("right", rightRotor, rightRing, rightStart)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Right rotor \"%s\" should be one of %s.\n", rightRotor, rotors)
+		os.Exit(1)
+	}
+
+	err = e.SetReflector(reflector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Reflector \"%s\" should be one of %s.\n", reflector, reflectors)
+		os.Exit(1)
+	}
+
+	err = e.AddPlugs(plugs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Plugs \"%s\" should be a comma seperated list of letter pairs.\n", *p)
+		os.Exit(1)
+	}
+
+	// Stream standard input straight through the machine unless the whole message is needed at once
+	if message == "-" && !*t && german != "out" {
+		if german == "in" {
+			options.Input = enigma.TransliterateNonLetters
+		}
+
+		encodeStream(&e, options)
+		return
+	}
+
+	if message == "-" {
+		message = readMessage()
+	}
+
+	if german == "in" {
+		message = enigma.NormaliseGerman(message, numbers)
+	}
+
+	if *t {
+		printTrace(e.EncodeTrace(message))
+		return
+	}
+
+	result, err := e.EncodeWithOptions(message, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Message \"%s\" should only contain letters and spaces.\n", message)
+		os.Exit(1)
+	}
+
+	if german == "out" {
+		result = enigma.DenormaliseGerman(result)
+	}
+
+	fmt.Println(result)
+}
+
+func parseRotor(position, input string) string {
+	if len(input) == 0 {
+		switch position {
+		case "Left":
+			return "III"
+		case "Middle":
+			return "II"
+		case "Right":
+		default:
+			return "I"
+		}
+	}
+
+	return input
+}
+
+func parseRing(position, input string) int {
+	if len(input) == 0 {
+		return 1
+	}
+
+	ring, err := strconv.Atoi(input)
+	if err != nil || ring < 1 || ring > 26 {
+		fmt.Fprintf(os.Stderr, "%s rotor ring setting \"%s\" should be a number between 1 and 26.\n", position, input)
+		os.Exit(1)
+	}
+
+	return ring
+}
+
+func parseStart(position, input string) rune {
+	if len(input) == 0 {
+		return 'A'
+	}
+
+	start := unicode.ToUpper(rune(input[0]))
+	if start < 'A' || start > 'Z' {
+		fmt.Fprintf(os.Stderr, "%s rotor start position \"%s\" should be a letter between A - Z.\n", position, input)
+		os.Exit(1)
+	}
+
+	return start
+}
+
+func parseReflector(input string) string {
+	if len(input) == 0 {
+		return "B"
+	}
+
+	return input
+}
+
+func parsePlugs(input string) []string {
+	if len(input) == 0 {
+		return []string{}
+	}
+
+	return strings.Split(input, ",")
+}
+
+func parseGerman(input string) string {
+	german := strings.ToLower(input)
+	if german != "" && german != "in" && german != "out" {
+		fmt.Fprintf(os.Stderr, "German conventions \"%s\" should be either in or out.\n", input)
+		os.Exit(1)
+	}
+
+	return german
+}
+
+func parseNumbers(input string) enigma.NumberStyle {
+	switch strings.ToLower(input) {
+	case "", "spell":
+		return enigma.SpellNumbers
+	case "bracket":
+		return enigma.BracketNumbers
+	}
+
+	fmt.Fprintf(os.Stderr, "Numbers \"%s\" should be either spell or bracket.\n", input)
+	os.Exit(1)
+
+	return enigma.SpellNumbers
+}
+
+func parseInput(input string) enigma.InputPolicy {
+	switch strings.ToLower(input) {
+	case "", "drop":
+		return enigma.DropNonLetters
+	case "pass":
+		return enigma.PassNonLetters
+	case "reject":
+		return enigma.RejectNonLetters
+	case "translit":
+		return enigma.TransliterateNonLetters
+	}
+
+	fmt.Fprintf(os.Stderr, "Input \"%s\" should be either drop, pass, reject or translit.\n", input)
+	os.Exit(1)
+
+	return enigma.DropNonLetters
+}
+
+func parseCount(name, input string, fallback int) int {
+	if len(input) == 0 {
+		return fallback
+	}
+
+	count, err := strconv.Atoi(input)
+	if err != nil || count < 0 {
+		fmt.Fprintf(os.Stderr, "%s \"%s\" should be a number of 0 or more.\n", name, input)
+		os.Exit(1)
+	}
+
+	return count
+}
+
+func printTrace(traces []enigma.Trace) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Key\tBefore\tAfter\tPlugs\tRight\tMiddle\tLeft\tReflector\tLeft\tMiddle\tRight\tLamp")
+
+	for _, tr := range traces {
+		fmt.Fprintf(w, "%c\t%s\t%s\t%c\t%c\t%c\t%c\t%c\t%c\t%c\t%c\t%c\n", tr.Input, tr.Before, tr.After, tr.PlugIn,
+			tr.Forward[0], tr.Forward[1], tr.Forward[2], tr.Reflected, tr.Backward[0], tr.Backward[1], tr.Backward[2],
+			tr.Output)
+	}
+
+	w.Flush()
+}
+
+func listWheels(wheels []enigma.Wheel) string {
+	names := make([]string, len(wheels))
+	for i, wheel := range wheels {
+		names[i] = wheel.Name
+	}
+
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+func printWheels(wheels []enigma.Wheel) {
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+
+	for _, wheel := range wheels {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", wheel.Name, wheel.Wiring, wheel.Notches, wheel.Model)
+	}
+
+	w.Flush()
+}
+
+func encodeStream(e *enigma.Enigma, options enigma.EncodeOptions) {
+	out := bufio.NewWriter(os.Stdout)
+	w := enigma.NewEncodingWriterWithOptions(out, e, options)
+
+	_, err := io.Copy(w, os.Stdin)
+	if err == nil {
+		err = w.Close()
+	}
+
+	if err != nil {
+		out.Flush()
+		fmt.Fprintf(os.Stderr, "\nFailed to encode standard input: %v.\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintln(out)
+	out.Flush()
+}
+
+func readMessage() string {
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read standard input: %v.\n", err)
+		os.Exit(1)
+	}
+
+	return string(input)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtraynor/enigma/bombe"
+)
+
+// Lists every offset at which the cribs could lie in the ciphertext, best menu first.
+func crib(args []string) {
+	flags := flag.NewFlagSet("crib", flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "Find where cribs could lie in a ciphertext, using the rule that no letter encodes to "+
+			"itself.\n\nUsage:\n enigma crib -c CIPHERTEXT CRIB [CRIB...]\n\nUse - as the ciphertext to read it from "+
+			"standard input.\n\nOptions:\n")
+		flags.PrintDefaults()
+	}
+
+	c := flags.String("c", "", "The ciphertext to place the cribs in.")
+	n := flags.String("n", "0", "The number of placements to list. 0 lists them all.")
+
+	flags.Parse(args)
+
+	ciphertext := *c
+	if len(ciphertext) == 0 || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if ciphertext == "-" {
+		ciphertext = readMessage()
+	}
+
+	menus := bombe.PlaceCribs(ciphertext, flags.Args()...)
+
+	count := parseCount("Count", *n, 0)
+	if count > 0 && count < len(menus) {
+		menus = menus[:count]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Offset\tCrib\tCiphertext\tLinks\tClosures\tStops")
+
+	for _, menu := range menus {
+		best := menu.Best()
+		cipher := menu.Ciphertext[menu.Offset : menu.Offset+len(menu.Crib)]
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%.4g\n", menu.Offset, menu.Crib, cipher, len(best.Links),
+			len(best.Closures()), best.ExpectedStops())
+	}
+
+	w.Flush()
+
+	if len(menus) == 0 {
+		fmt.Fprintf(os.Stderr, "No placements found for %s.\n", strings.Join(flags.Args(), ", "))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtraynor/enigma/analysis"
+)
+
+// Scores how closely each candidate text resembles natural language and lists them best first.
+func score(args []string) {
+	flags := flag.NewFlagSet("score", flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "Score how closely texts resemble natural language, to rank candidate decryptions.\n\n"+
+			"Usage:\n enigma score [OPTIONS] TEXT [TEXT...]\n\nN-gram scores are averaged over the text, so texts of "+
+			"different lengths can be compared.\nUse - as the text to read candidates from standard input, one on each "+
+			"line.\n\nOptions:\n")
+		flags.PrintDefaults()
+	}
+
+	l := flags.String("lang", "german", "The language of the built in n-gram tables. Either german or english.")
+	n := flags.String("n", "4", "The size of the n-grams, between 1 and 4, or ioc for the index of coincidence.")
+	t := flags.String("table", "", "A file of n-gram counts to score with instead of the built in tables.")
+
+	flags.Parse(args)
+
+	texts := flags.Args()
+	if len(texts) == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if len(texts) == 1 && texts[0] == "-" {
+		texts = readLines()
+	}
+
+	scorer := parseScorer(*l, *n, *t)
+
+	// N-gram scores add up over the whole text, so they are averaged to compare texts of different lengths
+	ngrams, average := scorer.(*analysis.Ngrams)
+
+	scores := make([]float64, len(texts))
+	for i, text := range texts {
+		scores[i] = scorer.Score([]byte(text))
+
+		if average {
+			scores[i] /= float64(max(countLetters(text)-ngrams.Size()+1, 1))
+		}
+	}
+
+	order := make([]int, len(texts))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Score\tText")
+
+	for _, i := range order {
+		fmt.Fprintf(w, "%.4f\t%s\n", scores[i], texts[i])
+	}
+
+	w.Flush()
+}
+
+func parseScorer(language, size, table string) analysis.Scorer {
+	if strings.ToLower(size) == "ioc" {
+		return analysis.IndexOfCoincidence{}
+	}
+
+	if len(table) > 0 {
+		file, err := os.Open(table)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open n-gram table: %v.\n", err)
+			os.Exit(1)
+		}
+
+		defer file.Close()
+
+		ngrams, err := analysis.LoadNgrams(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read n-gram table \"%s\": %v.\n", table, err)
+			os.Exit(1)
+		}
+
+		return ngrams
+	}
+
+	n := parseCount("N-gram size", size, 4)
+
+	ngrams, err := analysis.Builtin(language, n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Language \"%s\" should be either german or english, and the n-gram size \"%s\" "+
+			"between 1 and 4 or ioc.\n", language, size)
+		os.Exit(1)
+	}
+
+	return ngrams
+}
+
+func countLetters(text string) int {
+	count := 0
+	for _, letter := range strings.ToUpper(text) {
+		if letter >= 'A' && letter <= 'Z' {
+			count++
+		}
+	}
+
+	return count
+}
+
+func readLines() []string {
+	lines := []string{}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read standard input: %v.\n", err)
+		os.Exit(1)
+	}
+
+	return lines
+}
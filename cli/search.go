@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtraynor/enigma"
+)
+
+// Tries every key against a crib and lists those that encode it to the ciphertext.
+func search(args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "Try every key against a known piece of plaintext and list the keys that encode it to "+
+			"the ciphertext.\n\nUsage:\n enigma search [OPTIONS] -c CIPHERTEXT CRIB\n\nUse - as the ciphertext to read "+
+			"it from standard input.\nPress Ctrl-C to stop the search, saving the checkpoint if there is one.\n\n"+
+			"Options:\n")
+		flags.PrintDefaults()
+	}
+
+	c := flags.String("c", "", "The ciphertext to search.")
+	o := flags.String("offset", "0", "The offset of the crib in the ciphertext, counting from 0.")
+
+	ref := flags.String("ref", "", "A comma seperated list of the reflectors to try. Defaults to every reflector.")
+	rotors := flags.String("rotors", "", "A comma seperated list of the rotors to try. Defaults to every rotor.")
+
+	lr := flags.String("lr", "1-26", "The ring settings of the left rotor to try. e.g. \"1-26\" or \"5\".")
+	mr := flags.String("mr", "1-26", "The ring settings of the middle rotor to try. e.g. \"1-26\" or \"5\".")
+	rr := flags.String("rr", "1-26", "The ring settings of the right rotor to try. e.g. \"1-26\" or \"5\".")
+
+	p := flags.String("p", "", "A comma seperated list of letter pairs used with every key. e.g. \"AB,CD,EF\".")
+
+	cp := flags.String("checkpoint", "", "A file to save the progress of the search to, so that it can be resumed.")
+	i := flags.String("interval", "1m", "How often the checkpoint is saved. e.g. \"30s\" or \"5m\".")
+	r := flags.Bool("resume", false, "Carry on the search from the checkpoint instead of starting again.")
+
+	flags.Parse(args)
+
+	ciphertext := *c
+	if len(ciphertext) == 0 || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if ciphertext == "-" {
+		ciphertext = readMessage()
+	}
+
+	if *r && len(*cp) == 0 {
+		fmt.Fprint(os.Stderr, "Resume needs the checkpoint file to resume from.\n")
+		os.Exit(1)
+	}
+
+	interval, err := time.ParseDuration(*i)
+	if err != nil || interval <= 0 {
+		fmt.Fprintf(os.Stderr, "Interval \"%s\" should be a duration. e.g. \"30s\" or \"5m\".\n", *i)
+		os.Exit(1)
+	}
+
+	rings := [3]enigma.RingRange{
+		parseRingRange("Left", *lr),
+		parseRingRange("Middle", *mr),
+		parseRingRange("Right", *rr),
+	}
+
+	s := enigma.Search{
+		Reflectors: parseList(*ref),
+		Rotors:     parseList(*rotors),
+		Rings:      rings,
+		Plugs:      strings.Join(parsePlugs(*p), " "),
+		Checkpoint: *cp,
+		Interval:   interval,
+		Resume:     *r,
+	}
+
+	// Only whole percentages are printed, to keep the progress readable
+	percent := int64(-1)
+	s.Progress = func(done, total int64) {
+		if done*100/total != percent {
+			percent = done * 100 / total
+			fmt.Fprintf(os.Stderr, "\rSearched %d%% of %d keys.", percent, total)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	crib := strings.Join(flags.Args(), " ")
+
+	candidates, err := s.Run(ctx, crib, ciphertext, parseCount("Offset", *o, 0))
+	fmt.Fprintln(os.Stderr)
+
+	if err == context.Canceled {
+		fmt.Fprint(os.Stderr, "Search stopped. Only the keys found so far are listed.\n")
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to search: %v.\n", err)
+		os.Exit(1)
+	}
+
+	plaintexts, err := enigma.DecryptAll(ciphertext, candidates)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to decrypt: %v.\n", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Key\tPositions\tPlaintext")
+
+	for j, candidate := range candidates {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", candidate.Key, candidate.Positions, plaintexts[j])
+	}
+
+	w.Flush()
+
+	if len(candidates) == 0 {
+		fmt.Fprintf(os.Stderr, "No keys found for %s.\n", crib)
+		os.Exit(1)
+	}
+}
+
+func parseList(input string) []string {
+	if len(input) == 0 {
+		return nil
+	}
+
+	return strings.Split(input, ",")
+}
+
+func parseRingRange(position, input string) enigma.RingRange {
+	first, last, found := strings.Cut(input, "-")
+	if !found {
+		last = first
+	}
+
+	r := enigma.RingRange{}
+
+	var err1, err2 error
+	r.First, err1 = strconv.Atoi(first)
+	r.Last, err2 = strconv.Atoi(last)
+
+	if err1 != nil || err2 != nil || r.First < 1 || r.Last > 26 || r.First > r.Last {
+		fmt.Fprintf(os.Stderr, "%s rotor ring settings \"%s\" should be a range between 1 and 26. e.g. \"1-26\".\n",
+			position, input)
+		os.Exit(1)
+	}
+
+	return r
+}
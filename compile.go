@@ -0,0 +1,132 @@
+package enigma
+
+// Compiled holds the substitution an enigma applies to every letter of a message, worked out in advance for each
+// rotor position the enigma passes through. Once the rotors return to a position they have already been in the
+// substitutions repeat, so only one cycle is held. A Compiled is never changed once made and is safe to share.
+type Compiled struct {
+	tables [][26]byte
+	cycle  int
+}
+
+// Compile works out the substitution for every letter of a message starting from the current rotor positions, until
+// the positions repeat. With single notched rotors double stepping makes the cycle 16,900 letters long. The enigma
+// itself is left as it is.
+func (e Enigma) Compile() *Compiled {
+	c := e.Clone()
+	c.stepHooks = nil
+
+	seen := make([]int32, PositionCount)
+	for i := range seen {
+		seen[i] = -1
+	}
+
+	compiled := &Compiled{}
+
+	for i := int32(0); ; i++ {
+		c.rotors.rotate()
+
+		state := c.rotors.state()
+		if seen[state] >= 0 {
+			compiled.cycle = int(seen[state])
+			break
+		}
+
+		seen[state] = i
+
+		table := [26]byte{}
+		for j := range table {
+			table[j] = byte(c.scramble(rune('A' + j)))
+		}
+
+		compiled.tables = append(compiled.tables, table)
+	}
+
+	return compiled
+}
+
+// Period returns the number of letters after which the substitutions repeat.
+func (c *Compiled) Period() int {
+	return len(c.tables) - c.cycle
+}
+
+// Permutation returns the substitution applied to the letter at index i of a message, counting from 0, as the letters
+// that each of the letters A - Z become. The substitutions repeat every Period letters both ways, so a negative index
+// counts back around the cycle.
+func (c *Compiled) Permutation(i int) string {
+	table := c.table(i)
+
+	return string(table[:])
+}
+
+// Encode takes the input string, encodes each letter in turn from the position the enigma was compiled at and
+// returns the result, laid out in the same way as Enigma.Encode.
+func (c *Compiled) Encode(input string) string {
+	result, _ := c.EncodeWithOptions(input, DefaultEncodeOptions)
+
+	return result
+}
+
+// EncodeWithOptions takes the input string, encodes each letter in turn from the position the enigma was compiled at
+// and returns the result laid out as the options describe. Returns an error if the input policy rejects a character.
+func (c *Compiled) EncodeWithOptions(input string, options EncodeOptions) (string, error) {
+	enc := newEncoder(&compiledCursor{compiled: c}, options)
+
+	for _, letter := range input {
+		err := enc.write(letter)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	enc.flush()
+
+	return string(enc.out), nil
+}
+
+// AppendEncode encodes each ASCII letter of src in turn from the position the enigma was compiled at and appends the
+// upper case result to dst, returning the extended slice. Bytes that are not letters are ignored and the output is not
+// grouped.
+func (c *Compiled) AppendEncode(dst, src []byte) []byte {
+	i := 0
+
+	for _, b := range src {
+		letter := upperLetter(b)
+		if letter == 0 {
+			continue
+		}
+
+		dst = append(dst, c.table(i)[letter-'A'])
+		i++
+	}
+
+	return dst
+}
+
+// Returns the substitution applied to the letter at index i of a message, wrapping any index outside the tables around
+// the cycle.
+func (c *Compiled) table(i int) *[26]byte {
+	if i < 0 || i >= len(c.tables) {
+		period := c.Period()
+		i = c.cycle + ((i-c.cycle)%period+period)%period
+	}
+
+	return &c.tables[i]
+}
+
+// Walks through the compiled substitutions one letter at a time.
+type compiledCursor struct {
+	compiled *Compiled
+	index    int
+}
+
+func (cursor *compiledCursor) encodeLetter(letter rune) rune {
+	result := rune(cursor.compiled.table(cursor.index)[letter-'A'])
+	cursor.index++
+
+	return result
+}
+
+// Returns a number between 0 and PositionCount that is unique to the current rotor positions.
+func (rotors rotors) state() int {
+	return rotors[2].position*26*26 + rotors[1].position*26 + rotors[0].position
+}
@@ -1,155 +1,286 @@
-package enigma
-
-import (
-	"fmt"
-	"strings"
-	"unicode"
-)
-
-// The Enigma Machine
-type Enigma struct {
-	rotors    rotors
-	reflector rotor
-	plugs     plugs
-}
-
-// New returns an instance of the Enigma machine initialised with sensible defaults.
-// Left Rotor: I. Middle Rotor: II. Right Rotor: III. Reflector: B. No plugs.
-func New() Enigma {
-	leftRotor := availableRotors["III"]
-	middleRotor := availableRotors["II"]
-	rightRotor := availableRotors["I"]
-	reflector := availableReflectors["B"]
-
-	return Enigma{
-		rotors: rotors{
-			&rightRotor,
-			&middleRotor,
-			&leftRotor,
-		},
-		reflector: reflector,
-	}
-}
-
-// Encode takes the input string, encodes each letter in turn and returns the result.
-// Input characters that are not letters are ignored.
-func (e Enigma) Encode(input string) string {
-	result := ""
-
-	count := 0
-
-	for _, letter := range strings.ToUpper(input) {
-		if letter < 'A' || letter > 'Z' {
-			continue
-		}
-
-		// Every 5 letters add a space
-		if count > 0 && count%5 == 0 {
-			result += " "
-		}
-
-		count++
-
-		e.rotors.rotate()
-
-		// Plugs on the way in
-		letter = e.plugs.replace(letter)
-
-		// Encode right to left
-		letter = e.rotors.encode(letter, false)
-
-		letter = e.reflector.encode(letter)
-
-		// Encode left to right
-		letter = e.rotors.encode(letter, true)
-
-		// Plugs on the way out
-		letter = e.plugs.replace(letter)
-
-		result += runeToString[letter]
-	}
-
-	return result
-}
-
-// SetRotor looks up a rotor of the provided name, sets the ring and start positions, then adds the rotor to the enigma in
-// the given position. Returns an error if a rotor of that name is not available or the position does not exist.
-// Valid Positions: LEFT, MIDDLE, RIGHT. Available Rotors: I, II, III, IV, V, VI, VII, VIII.
-func (e *Enigma) SetRotor(position, name string, ringPosition int, startPosition rune) error {
-	rotor, check := availableRotors[name]
-	if !check {
-		return fmt.Errorf("no such rotor: %s", name)
-	}
-
-	index, check := rotorPositions[strings.ToUpper(position)]
-	if !check {
-		return fmt.Errorf("no such position: %s", position)
-	}
-
-	if ringPosition < 1 || ringPosition > 26 {
-		return fmt.Errorf("invalid ring position: %d", ringPosition)
-	}
-
-	start := unicode.ToUpper(startPosition)
-	if start < 'A' || start > 'Z' {
-		return fmt.Errorf("invalid start position: %c", start)
-	}
-
-	rotor.setRingPosition(ringPosition)
-
-	rotor.setStartPosition(start)
-
-	e.rotors[index] = &rotor
-
-	return nil
-}
-
-// SetReflector looks up a reflector of the provided name and adds it to the enigma.
-// Returns an error if a reflector of that name is not available. Available Reflectors: B, C.
-func (e *Enigma) SetReflector(name string) error {
-	reflector, check := availableReflectors[name]
-	if !check {
-		return fmt.Errorf("no such relector: %s", name)
-	}
-
-	e.reflector = reflector
-
-	return nil
-}
-
-// AddPlugs takes an array of 2 character input strings and adds each pair as a plug to the enigma.
-func (e *Enigma) AddPlugs(inputs []string) error {
-	for _, plug := range inputs {
-		err := e.AddPlug(plug)
-		if err != nil {
-			return fmt.Errorf("failed to add plug: %v", err)
-		}
-	}
-
-	return nil
-}
-
-// AddPlug takes a 2 character input string and adds the pair as a plug to the enigma.
-// Returns and error if either character of the input plug is already used by an existing plug.
-func (e *Enigma) AddPlug(input string) error {
-	if len(input) != 2 {
-		return fmt.Errorf("invalid length: %s", input)
-	}
-
-	one := unicode.ToUpper(rune(input[0]))
-	two := unicode.ToUpper(rune(input[1]))
-
-	if one < 'A' || one > 'Z' || two < 'A' || two > 'Z' {
-		return fmt.Errorf("invalid plug: %s", input)
-	}
-
-	for _, p := range e.plugs {
-		if one == p[0] || one == p[1] || two == p[0] || two == p[1] {
-			return fmt.Errorf("duplicate plugs: %s %s", p, input)
-		}
-	}
-
-	e.plugs = append(e.plugs, plug{one, two})
-
-	return nil
-}
+package enigma
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// The Enigma Machine
+type Enigma struct {
+	rotors    rotors
+	reflector rotor
+	plugs     plugs
+	plugLimit int
+	stepHooks []func(StepEvent)
+}
+
+// HistoricalPlugLimit is the number of plug cables issued with each Wehrmacht machine.
+const HistoricalPlugLimit = 10
+
+// New returns an instance of the Enigma machine initialised with sensible defaults.
+// Left Rotor: I. Middle Rotor: II. Right Rotor: III. Reflector: B. No plugs.
+func New() Enigma {
+	leftRotor := availableRotors["III"]
+	middleRotor := availableRotors["II"]
+	rightRotor := availableRotors["I"]
+	reflector := availableReflectors["B"]
+
+	return Enigma{
+		rotors: rotors{
+			&rightRotor,
+			&middleRotor,
+			&leftRotor,
+		},
+		reflector: reflector,
+	}
+}
+
+// Encode takes the input string, encodes each letter in turn and returns the result.
+// Input characters that are not letters are ignored.
+func (e Enigma) Encode(input string) string {
+	result, _ := e.EncodeWithOptions(input, DefaultEncodeOptions)
+
+	return result
+}
+
+// EncodeWithOptions takes the input string, encodes each letter in turn and returns the result laid out as the options
+// describe. Returns an error if the input policy rejects a character, by which point any earlier letters have already
+// stepped the rotors.
+func (e Enigma) EncodeWithOptions(input string, options EncodeOptions) (string, error) {
+	enc := newEncoder(&e, options)
+
+	for _, letter := range input {
+		err := enc.write(letter)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	enc.flush()
+
+	return string(enc.out), nil
+}
+
+// Press presses a single key, stepping the rotors and encoding the letter, then returns the letter of the lamp that
+// lights up. Returns an error if the key is not a letter.
+func (e *Enigma) Press(letter rune) (rune, error) {
+	key := unicode.ToUpper(letter)
+	if key < 'A' || key > 'Z' {
+		return 0, fmt.Errorf("invalid key: %c", letter)
+	}
+
+	return e.encodeLetter(key), nil
+}
+
+// Moved reports which rotors stepped on the most recent key press, whether the key was pressed by Press or as part of
+// encoding a message.
+func (e Enigma) Moved() (left, middle, right bool) {
+	return e.rotors[2].turned, e.rotors[1].turned, e.rotors[0].turned
+}
+
+// Positions returns the letters currently showing in the windows of the left, middle and right rotors.
+func (e Enigma) Positions() string {
+	return e.rotors.positions()
+}
+
+// Steps the rotors then passes a single upper case letter through the plugs, rotors and reflector.
+func (e *Enigma) encodeLetter(letter rune) rune {
+	e.step()
+
+	return e.scramble(letter)
+}
+
+// Passes a single upper case letter through the plugs, rotors and reflector without stepping the rotors.
+func (e *Enigma) scramble(letter rune) rune {
+	// Plugs on the way in
+	letter = e.plugs.replace(letter)
+
+	// Encode right to left
+	letter = e.rotors.encode(letter, false)
+
+	letter = e.reflector.encode(letter)
+
+	// Encode left to right
+	letter = e.rotors.encode(letter, true)
+
+	// Plugs on the way out
+	return e.plugs.replace(letter)
+}
+
+// Clone returns a copy of the enigma with its own rotors and plugs, so that the copy and the original can be used
+// independently of each other, including on different goroutines. Any step hooks are shared by both.
+func (e Enigma) Clone() Enigma {
+	c := e
+
+	for i, r := range e.rotors {
+		rotor := *r
+		c.rotors[i] = &rotor
+	}
+
+	c.plugs = append(plugs(nil), e.plugs...)
+
+	return c
+}
+
+// SetRotor looks up a rotor of the provided name, sets the ring and start positions, then adds the rotor to the enigma in
+// the given position. Returns an error if a rotor of that name is not available or the position does not exist.
+// Valid Positions: LEFT, MIDDLE, RIGHT. Available Rotors: I, II, III, IV, V, VI, VII, VIII.
+func (e *Enigma) SetRotor(position, name string, ringPosition int, startPosition rune) error {
+	rotor, check := availableRotors[name]
+	if !check {
+		return fmt.Errorf("no such rotor: %s", name)
+	}
+
+	index, check := rotorPositions[strings.ToUpper(position)]
+	if !check {
+		return fmt.Errorf("no such position: %s", position)
+	}
+
+	if ringPosition < 1 || ringPosition > 26 {
+		return fmt.Errorf("invalid ring position: %d", ringPosition)
+	}
+
+	start := unicode.ToUpper(startPosition)
+	if start < 'A' || start > 'Z' {
+		return fmt.Errorf("invalid start position: %c", start)
+	}
+
+	rotor.setRingPosition(ringPosition)
+
+	rotor.setStartPosition(start)
+
+	e.rotors[index] = &rotor
+
+	return nil
+}
+
+// SetReflector looks up a reflector of the provided name and adds it to the enigma.
+// Returns an error if a reflector of that name is not available. Available Reflectors: B, C.
+func (e *Enigma) SetReflector(name string) error {
+	reflector, check := availableReflectors[name]
+	if !check {
+		return fmt.Errorf("no such relector: %s", name)
+	}
+
+	e.reflector = reflector
+
+	return nil
+}
+
+// AddPlugs takes an array of 2 character input strings and adds each pair as a plug to the enigma.
+func (e *Enigma) AddPlugs(inputs []string) error {
+	for _, plug := range inputs {
+		err := e.AddPlug(plug)
+		if err != nil {
+			return fmt.Errorf("failed to add plug: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// AddPlug takes a 2 character input string and adds the pair as a plug to the enigma.
+// Returns and error if either character of the input plug is already used by an existing plug, or if the enigma already
+// has as many plugs as its limit allows.
+func (e *Enigma) AddPlug(input string) error {
+	p, err := parsePlug(input)
+	if err != nil {
+		return err
+	}
+
+	for _, existing := range e.plugs {
+		if p[0] == existing[0] || p[0] == existing[1] || p[1] == existing[0] || p[1] == existing[1] {
+			return fmt.Errorf("duplicate plugs: %s %s", existing, input)
+		}
+	}
+
+	if e.plugLimit > 0 && len(e.plugs) >= e.plugLimit {
+		return fmt.Errorf("too many plugs: %s exceeds the limit of %d", input, e.plugLimit)
+	}
+
+	e.plugs = append(e.plugs, p)
+
+	return nil
+}
+
+// RemovePlug takes a 2 character input string and removes the plug connecting that pair from the enigma. The letters
+// may be in either order. Returns an error if there is no such plug.
+func (e *Enigma) RemovePlug(input string) error {
+	p, err := parsePlug(input)
+	if err != nil {
+		return err
+	}
+
+	for i, existing := range e.plugs {
+		if existing == p || existing == (plug{p[1], p[0]}) {
+			e.plugs = append(e.plugs[:i:i], e.plugs[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no such plug: %s", input)
+}
+
+// ClearPlugs removes every plug from the enigma.
+func (e *Enigma) ClearPlugs() {
+	e.plugs = nil
+}
+
+// Plugs returns the pair of letters connected by each plug, in the order they were added.
+func (e Enigma) Plugs() []string {
+	pairs := make([]string, len(e.plugs))
+	for i, p := range e.plugs {
+		pairs[i] = p.String()
+	}
+
+	return pairs
+}
+
+// SetPlugboard replaces every plug on the enigma with the space separated letter pairs of the board. e.g. "AB CD EF".
+// Returns an error, leaving the existing plugs in place, if any of the pairs cannot be added.
+func (e *Enigma) SetPlugboard(board string) error {
+	existing := e.plugs
+	e.plugs = nil
+
+	err := e.AddPlugs(strings.Fields(board))
+	if err != nil {
+		e.plugs = existing
+		return err
+	}
+
+	return nil
+}
+
+// SetPlugLimit caps the number of plugs the enigma accepts. A limit of 0 allows any number of plugs. The Wehrmacht
+// issued 10 cables with each machine, see HistoricalPlugLimit. Returns an error if the limit is negative or the enigma
+// already has more plugs than the limit.
+func (e *Enigma) SetPlugLimit(limit int) error {
+	if limit < 0 {
+		return fmt.Errorf("invalid plug limit: %d", limit)
+	}
+
+	if limit > 0 && len(e.plugs) > limit {
+		return fmt.Errorf("too many plugs: %d exceeds the limit of %d", len(e.plugs), limit)
+	}
+
+	e.plugLimit = limit
+
+	return nil
+}
+
+// Checks a 2 character input string is a pair of letters and returns it as a plug.
+func parsePlug(input string) (plug, error) {
+	if len(input) != 2 {
+		return plug{}, fmt.Errorf("invalid length: %s", input)
+	}
+
+	one := unicode.ToUpper(rune(input[0]))
+	two := unicode.ToUpper(rune(input[1]))
+
+	if one < 'A' || one > 'Z' || two < 'A' || two > 'Z' {
+		return plug{}, fmt.Errorf("invalid plug: %s", input)
+	}
+
+	return plug{one, two}, nil
+}
@@ -1,84 +1,235 @@
-package enigma_test
-
-import (
-	"testing"
-
-	"github.com/jtraynor/enigma"
-)
-
-var letters = []string{
-	"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M",
-	"N", "O", "P", "Q", "R", "S", "T", "U", "V", "W", "X", "Y", "Z",
-}
-
-func BenchmarkDefault(b *testing.B) {
-	e := enigma.New()
-
-	for n := 0; n < b.N; n++ {
-		e.Encode(letters[n%26])
-	}
-}
-
-func BenchmarkWithLaterRotors(b *testing.B) {
-	e := enigma.New()
-
-	err := e.SetRotor("left", "VI", 1, 'A')
-	if err != nil {
-		b.Fatalf("Setup Failed: %v.", err)
-	}
-
-	err = e.SetRotor("middle", "VII", 1, 'A')
-	if err != nil {
-		b.Fatalf("Setup Failed: %v.", err)
-	}
-
-	err = e.SetRotor("right", "VIII", 1, 'A')
-	if err != nil {
-		b.Fatalf("Setup Failed: %v.", err)
-	}
-
-	for n := 0; n < b.N; n++ {
-		e.Encode(letters[n%26])
-	}
-}
-
-func BenchmarkWithPlugs(b *testing.B) {
-	e := enigma.New()
-
-	err := e.AddPlugs([]string{"AB", "CD", "EF", "GH", "IJ", "KL", "NM", "OP", "QR", "ST", "UV", "WX", "YZ"})
-	if err != nil {
-		b.Fatalf("Setup Failed: %v.", err)
-	}
-
-	for n := 0; n < b.N; n++ {
-		e.Encode(letters[n%26])
-	}
-}
-
-func BenchmarkWithLaterRotorsAndPlugs(b *testing.B) {
-	e := enigma.New()
-
-	err := e.SetRotor("left", "VI", 1, 'A')
-	if err != nil {
-		b.Fatalf("Setup Failed: %v.", err)
-	}
-
-	err = e.SetRotor("middle", "VII", 1, 'A')
-	if err != nil {
-		b.Fatalf("Setup Failed: %v.", err)
-	}
-
-	err = e.SetRotor("right", "VIII", 1, 'A')
-	if err != nil {
-		b.Fatalf("Setup Failed: %v.", err)
-	}
-
-	err = e.AddPlugs([]string{"AB", "CD", "EF", "GH", "IJ", "KL", "NM", "OP", "QR", "ST", "UV", "WX", "YZ"})
-	if err != nil {
-		b.Fatalf("Setup Failed: %v.", err)
-	}
-
-	for n := 0; n < b.N; n++ {
-		e.Encode(letters[n%26])
-	}
-}
+package enigma_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jtraynor/enigma"
+)
+
+var letters = []string{
+	"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M",
+	"N", "O", "P", "Q", "R", "S", "T", "U", "V", "W", "X", "Y", "Z",
+}
+
+func BenchmarkDefault(b *testing.B) {
+	e := enigma.New()
+
+	for n := 0; n < b.N; n++ {
+		e.Encode(letters[n%26])
+	}
+}
+
+func BenchmarkWithLaterRotors(b *testing.B) {
+	e := enigma.New()
+
+	err := e.SetRotor("left", "VI", 1, 'A')
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = e.SetRotor("middle", "VII", 1, 'A')
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = e.SetRotor("right", "VIII", 1, 'A')
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	for n := 0; n < b.N; n++ {
+		e.Encode(letters[n%26])
+	}
+}
+
+func BenchmarkWithPlugs(b *testing.B) {
+	e := enigma.New()
+
+	err := e.AddPlugs([]string{"AB", "CD", "EF", "GH", "IJ", "KL", "NM", "OP", "QR", "ST", "UV", "WX", "YZ"})
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	for n := 0; n < b.N; n++ {
+		e.Encode(letters[n%26])
+	}
+}
+
+func BenchmarkWithLaterRotorsAndPlugs(b *testing.B) {
+	e := enigma.New()
+
+	err := e.SetRotor("left", "VI", 1, 'A')
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = e.SetRotor("middle", "VII", 1, 'A')
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = e.SetRotor("right", "VIII", 1, 'A')
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = e.AddPlugs([]string{"AB", "CD", "EF", "GH", "IJ", "KL", "NM", "OP", "QR", "ST", "UV", "WX", "YZ"})
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	for n := 0; n < b.N; n++ {
+		e.Encode(letters[n%26])
+	}
+}
+
+func BenchmarkEncodingWriter(b *testing.B) {
+	e := enigma.New()
+
+	chunk := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 1000))
+
+	w := enigma.NewEncodingWriter(io.Discard, &e)
+
+	b.SetBytes(int64(len(chunk)))
+
+	for n := 0; n < b.N; n++ {
+		_, err := w.Write(chunk)
+		if err != nil {
+			b.Fatalf("Write Failed: %v.", err)
+		}
+	}
+}
+
+var kilobytes = []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 4096/45+1)[:4096])
+
+func BenchmarkAppendEncode4K(b *testing.B) {
+	e := enigma.New()
+
+	err := e.AddPlugs([]string{"AB", "CD", "EF", "GH", "IJ", "KL", "NM", "OP", "QR", "ST"})
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	dst := make([]byte, 0, len(kilobytes))
+
+	b.SetBytes(int64(len(kilobytes)))
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		dst = e.AppendEncode(dst[:0], kilobytes)
+	}
+}
+
+func BenchmarkEncodeInPlace4K(b *testing.B) {
+	e := enigma.New()
+
+	err := e.AddPlugs([]string{"AB", "CD", "EF", "GH", "IJ", "KL", "NM", "OP", "QR", "ST"})
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	buf := make([]byte, len(kilobytes))
+	copy(buf, kilobytes)
+
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		e.EncodeInPlace(buf)
+	}
+}
+
+func BenchmarkEncode4K(b *testing.B) {
+	e := enigma.New()
+
+	err := e.AddPlugs([]string{"AB", "CD", "EF", "GH", "IJ", "KL", "NM", "OP", "QR", "ST"})
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	input := string(kilobytes)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		e.Encode(input)
+	}
+}
+
+func BenchmarkCompiledAppendEncode4K(b *testing.B) {
+	e := enigma.New()
+
+	err := e.AddPlugs([]string{"AB", "CD", "EF", "GH", "IJ", "KL", "NM", "OP", "QR", "ST"})
+	if err != nil {
+		b.Fatalf("Setup Failed: %v.", err)
+	}
+
+	compiled := e.Compile()
+
+	dst := make([]byte, 0, len(kilobytes))
+
+	b.SetBytes(int64(len(kilobytes)))
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		dst = compiled.AppendEncode(dst[:0], kilobytes)
+	}
+}
+
+func BenchmarkEncodeParallel1M(b *testing.B) {
+	e := enigma.New()
+
+	input := strings.Repeat(string(kilobytes), 256)
+
+	b.SetBytes(int64(len(input)))
+
+	for n := 0; n < b.N; n++ {
+		e.EncodeParallel(input, 0)
+	}
+}
+
+func BenchmarkScoreAll(b *testing.B) {
+	ciphertext := string(kilobytes[:250])
+
+	keys := []enigma.Key{}
+	for _, rotors := range [][3]string{{"I", "II", "III"}, {"IV", "V", "I"}, {"VI", "VII", "VIII"}} {
+		key, err := enigma.NewKey("B", rotors, [3]int{1, 1, 1}, "AB CD EF GH IJ KL MN OP QR ST")
+		if err != nil {
+			b.Fatalf("Setup Failed: %v.", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	candidates := []enigma.Candidate{}
+	for _, key := range keys {
+		for _, position := range letters {
+			candidates = append(candidates, enigma.Candidate{Key: key, Positions: "AA" + position})
+		}
+	}
+
+	countE := func(plaintext []byte) float64 {
+		count := 0
+		for _, letter := range plaintext {
+			if letter == 'E' {
+				count++
+			}
+		}
+
+		return float64(count)
+	}
+
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		_, err := enigma.ScoreAll(ciphertext, candidates, countE)
+		if err != nil {
+			b.Fatalf("Score Failed: %v.", err)
+		}
+	}
+
+	b.ReportMetric(float64(b.N*len(candidates))/b.Elapsed().Seconds(), "keys/s")
+}
@@ -1,207 +1,1556 @@
-package enigma_test
-
-import (
-	"testing"
-
-	"github.com/jtraynor/enigma"
-)
-
-type testRotorSettings struct {
-	position string
-	name     string
-	ring     int
-	start    rune
-}
-
-var encodeTests = map[string]struct {
-	leftRotor   testRotorSettings
-	middleRotor testRotorSettings
-	rightRotor  testRotorSettings
-	reflector   string
-	plugs       []string
-	input       string
-	expected    string
-}{
-	"Fox Pangram": {
-		leftRotor:   testRotorSettings{"left", "V", 15, 'C'},
-		middleRotor: testRotorSettings{"middle", "II", 21, 'I'},
-		rightRotor:  testRotorSettings{"right", "VII", 15, 'K'},
-		reflector:   "B",
-		plugs:       []string{"AB", "CD", "EF"},
-		input:       "The quick brown fox jumps over the lazy dog",
-		expected:    "UGNIJ SHAKK IHFLP WKJJZ EMYWT HUFEY CFLGU",
-	},
-	"Sphinx Pangram": {
-		leftRotor:   testRotorSettings{"left", "IV", 4, 'Q'},
-		middleRotor: testRotorSettings{"middle", "II", 9, 'D'},
-		rightRotor:  testRotorSettings{"right", "VIII", 19, 'H'},
-		reflector:   "B",
-		plugs:       []string{"KL", "MN", "OP"},
-		input:       "Sphinx of black quartz, judge my vow",
-		expected:    "QDJSX DDWOV GZJFH FFEJX PMIVS NAFL",
-	},
-	"Liquor Pangram": {
-		leftRotor:   testRotorSettings{"left", "V", 6, 'Z'},
-		middleRotor: testRotorSettings{"middle", "III", 25, 'C'},
-		rightRotor:  testRotorSettings{"right", "II", 14, 'I'},
-		reflector:   "C",
-		plugs:       []string{"UV", "WX", "YZ"},
-		input:       "Pack my box with five dozen liquor jugs",
-		expected:    "TUOJI JYBFG AZEMJ AJCSV KMOPN DNSMD AT",
-	},
-}
-
-func TestEncode(t *testing.T) {
-	for name, tc := range encodeTests {
-		t.Run(name, func(t *testing.T) {
-			e := enigma.New()
-
-			err := e.SetRotor(tc.leftRotor.position, tc.leftRotor.name, tc.leftRotor.ring, tc.leftRotor.start)
-			if err != nil {
-				t.Fatalf("Setup Failed: %v.", err)
-			}
-
-			err = e.SetRotor(tc.middleRotor.position, tc.middleRotor.name, tc.middleRotor.ring, tc.middleRotor.start)
-			if err != nil {
-				t.Fatalf("Setup Failed: %v.", err)
-			}
-
-			err = e.SetRotor(tc.rightRotor.position, tc.rightRotor.name, tc.rightRotor.ring, tc.rightRotor.start)
-			if err != nil {
-				t.Fatalf("Setup Failed: %v.", err)
-			}
-
-			err = e.SetReflector(tc.reflector)
-			if err != nil {
-				t.Fatalf("Setup Failed: %v.", err)
-			}
-
-			err = e.AddPlugs(tc.plugs)
-			if err != nil {
-				t.Fatalf("Setup Failed: %v.", err)
-			}
-
-			result := e.Encode(tc.input)
-			if result != tc.expected {
-				t.Errorf("Failed %s.\nExpected: %s.\nResult:   %s.", name, tc.expected, result)
-			}
-		})
-	}
-}
-
-var setRotorTests = map[string]struct {
-	rotor           testRotorSettings
-	isErrorExpected bool
-}{
-	"Invalid Rotor": {
-		rotor: testRotorSettings{
-			name: "X",
-		},
-		isErrorExpected: true,
-	},
-	"Invalid Position": {
-		rotor: testRotorSettings{
-			name:     "I",
-			position: "X",
-		},
-		isErrorExpected: true,
-	},
-	"Invalid Ring": {
-		rotor: testRotorSettings{
-			name:     "I",
-			position: "LEFT",
-			ring:     0,
-		},
-		isErrorExpected: true,
-	},
-	"Invalid Start": {
-		rotor: testRotorSettings{
-			name:     "I",
-			position: "LEFT",
-			ring:     1,
-			start:    ' ',
-		},
-		isErrorExpected: true,
-	},
-	"Valid": {
-		rotor: testRotorSettings{
-			name:     "I",
-			position: "LEFT",
-			ring:     1,
-			start:    'A',
-		},
-	},
-}
-
-func TestSetRotor(t *testing.T) {
-	for name, tc := range setRotorTests {
-		t.Run(name, func(t *testing.T) {
-			e := enigma.New()
-
-			err := e.SetRotor(tc.rotor.position, tc.rotor.name, tc.rotor.ring, tc.rotor.start)
-			if tc.isErrorExpected == (err == nil) {
-				t.Errorf("Failed %s. Error: %v.", name, err)
-			}
-		})
-	}
-}
-
-var setReflectorTests = map[string]struct {
-	reflectorName   string
-	isErrorExpected bool
-}{
-	"Invalid Rotor": {
-		reflectorName:   "X",
-		isErrorExpected: true,
-	},
-	"Valid": {
-		reflectorName: "B",
-	},
-}
-
-func TestSetReflector(t *testing.T) {
-	for name, tc := range setReflectorTests {
-		t.Run(name, func(t *testing.T) {
-			e := enigma.New()
-
-			err := e.SetReflector(tc.reflectorName)
-			if tc.isErrorExpected == (err == nil) {
-				t.Errorf("Failed %s. Error: %v.", name, err)
-			}
-		})
-	}
-}
-
-var addPlugTests = map[string]struct {
-	plugs           []string
-	isErrorExpected bool
-}{
-	"Invalid Length": {
-		plugs:           []string{"X"},
-		isErrorExpected: true,
-	},
-	"Invalid Letter": {
-		plugs:           []string{"++"},
-		isErrorExpected: true,
-	},
-	"Duplicate": {
-		plugs:           []string{"AB", "BC"},
-		isErrorExpected: true,
-	},
-	"Valid": {
-		plugs: []string{"AB", "CD"},
-	},
-}
-
-func TestAddPlugs(t *testing.T) {
-	for name, tc := range addPlugTests {
-		t.Run(name, func(t *testing.T) {
-			e := enigma.New()
-
-			err := e.AddPlugs(tc.plugs)
-			if tc.isErrorExpected == (err == nil) {
-				t.Errorf("Failed %s. Error: %v.", name, err)
-			}
-		})
-	}
-}
+package enigma_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/jtraynor/enigma"
+)
+
+type testRotorSettings struct {
+	position string
+	name     string
+	ring     int
+	start    rune
+}
+
+var encodeTests = map[string]struct {
+	leftRotor   testRotorSettings
+	middleRotor testRotorSettings
+	rightRotor  testRotorSettings
+	reflector   string
+	plugs       []string
+	input       string
+	expected    string
+}{
+	"Fox Pangram": {
+		leftRotor:   testRotorSettings{"left", "V", 15, 'C'},
+		middleRotor: testRotorSettings{"middle", "II", 21, 'I'},
+		rightRotor:  testRotorSettings{"right", "VII", 15, 'K'},
+		reflector:   "B",
+		plugs:       []string{"AB", "CD", "EF"},
+		input:       "The quick brown fox jumps over the lazy dog",
+		expected:    "UGNIJ SHAKK IHFLP WKJJZ EMYWT HUFEY CFLGU",
+	},
+	"Sphinx Pangram": {
+		leftRotor:   testRotorSettings{"left", "IV", 4, 'Q'},
+		middleRotor: testRotorSettings{"middle", "II", 9, 'D'},
+		rightRotor:  testRotorSettings{"right", "VIII", 19, 'H'},
+		reflector:   "B",
+		plugs:       []string{"KL", "MN", "OP"},
+		input:       "Sphinx of black quartz, judge my vow",
+		expected:    "QDJSX DDWOV GZJFH FFEJX PMIVS NAFL",
+	},
+	"Liquor Pangram": {
+		leftRotor:   testRotorSettings{"left", "V", 6, 'Z'},
+		middleRotor: testRotorSettings{"middle", "III", 25, 'C'},
+		rightRotor:  testRotorSettings{"right", "II", 14, 'I'},
+		reflector:   "C",
+		plugs:       []string{"UV", "WX", "YZ"},
+		input:       "Pack my box with five dozen liquor jugs",
+		expected:    "TUOJI JYBFG AZEMJ AJCSV KMOPN DNSMD AT",
+	},
+	"Boxer Pangram Repeated": {
+		leftRotor:   testRotorSettings{"left", "VI", 3, 'Y'},
+		middleRotor: testRotorSettings{"middle", "VIII", 24, 'M'},
+		rightRotor:  testRotorSettings{"right", "VII", 11, 'L'},
+		reflector:   "C",
+		plugs:       []string{"QW", "ER"},
+		input: "Zwei Boxkaempfer jagen Eva quer durch Sylt Zwei Boxkaempfer jagen Eva quer durch Sylt " +
+			"Zwei Boxkaempfer jagen Eva quer durch Sylt Zwei Boxkaempfer jagen Eva quer durch Sylt",
+		expected: "RGQJQ BZTOB IBLBY XVCXF RYXWS NYEDW DSYEK HEXGV VAPQE DUDNT ELDMY XTUBL TVAUB THUZX MADNC " +
+			"ZCAFR YYRJA LJUXB PEXBV MVBJZ EGGLI BXPDM ICVUN PXYKL JGDFZ LNHNG OZZNM VRSPI RIHO",
+	},
+}
+
+func TestEncode(t *testing.T) {
+	for name, tc := range encodeTests {
+		t.Run(name, func(t *testing.T) {
+			e := enigma.New()
+
+			err := e.SetRotor(tc.leftRotor.position, tc.leftRotor.name, tc.leftRotor.ring, tc.leftRotor.start)
+			if err != nil {
+				t.Fatalf("Setup Failed: %v.", err)
+			}
+
+			err = e.SetRotor(tc.middleRotor.position, tc.middleRotor.name, tc.middleRotor.ring, tc.middleRotor.start)
+			if err != nil {
+				t.Fatalf("Setup Failed: %v.", err)
+			}
+
+			err = e.SetRotor(tc.rightRotor.position, tc.rightRotor.name, tc.rightRotor.ring, tc.rightRotor.start)
+			if err != nil {
+				t.Fatalf("Setup Failed: %v.", err)
+			}
+
+			err = e.SetReflector(tc.reflector)
+			if err != nil {
+				t.Fatalf("Setup Failed: %v.", err)
+			}
+
+			err = e.AddPlugs(tc.plugs)
+			if err != nil {
+				t.Fatalf("Setup Failed: %v.", err)
+			}
+
+			result := e.Encode(tc.input)
+			if result != tc.expected {
+				t.Errorf("Failed %s.\nExpected: %s.\nResult:   %s.", name, tc.expected, result)
+			}
+		})
+	}
+}
+
+var setRotorTests = map[string]struct {
+	rotor           testRotorSettings
+	isErrorExpected bool
+}{
+	"Invalid Rotor": {
+		rotor: testRotorSettings{
+			name: "X",
+		},
+		isErrorExpected: true,
+	},
+	"Invalid Position": {
+		rotor: testRotorSettings{
+			name:     "I",
+			position: "X",
+		},
+		isErrorExpected: true,
+	},
+	"Invalid Ring": {
+		rotor: testRotorSettings{
+			name:     "I",
+			position: "LEFT",
+			ring:     0,
+		},
+		isErrorExpected: true,
+	},
+	"Invalid Start": {
+		rotor: testRotorSettings{
+			name:     "I",
+			position: "LEFT",
+			ring:     1,
+			start:    ' ',
+		},
+		isErrorExpected: true,
+	},
+	"Valid": {
+		rotor: testRotorSettings{
+			name:     "I",
+			position: "LEFT",
+			ring:     1,
+			start:    'A',
+		},
+	},
+}
+
+func TestSetRotor(t *testing.T) {
+	for name, tc := range setRotorTests {
+		t.Run(name, func(t *testing.T) {
+			e := enigma.New()
+
+			err := e.SetRotor(tc.rotor.position, tc.rotor.name, tc.rotor.ring, tc.rotor.start)
+			if tc.isErrorExpected == (err == nil) {
+				t.Errorf("Failed %s. Error: %v.", name, err)
+			}
+		})
+	}
+}
+
+var setReflectorTests = map[string]struct {
+	reflectorName   string
+	isErrorExpected bool
+}{
+	"Invalid Rotor": {
+		reflectorName:   "X",
+		isErrorExpected: true,
+	},
+	"Valid": {
+		reflectorName: "B",
+	},
+}
+
+func TestSetReflector(t *testing.T) {
+	for name, tc := range setReflectorTests {
+		t.Run(name, func(t *testing.T) {
+			e := enigma.New()
+
+			err := e.SetReflector(tc.reflectorName)
+			if tc.isErrorExpected == (err == nil) {
+				t.Errorf("Failed %s. Error: %v.", name, err)
+			}
+		})
+	}
+}
+
+var addPlugTests = map[string]struct {
+	plugs           []string
+	isErrorExpected bool
+}{
+	"Invalid Length": {
+		plugs:           []string{"X"},
+		isErrorExpected: true,
+	},
+	"Invalid Letter": {
+		plugs:           []string{"++"},
+		isErrorExpected: true,
+	},
+	"Duplicate": {
+		plugs:           []string{"AB", "BC"},
+		isErrorExpected: true,
+	},
+	"Valid": {
+		plugs: []string{"AB", "CD"},
+	},
+}
+
+func TestAddPlugs(t *testing.T) {
+	for name, tc := range addPlugTests {
+		t.Run(name, func(t *testing.T) {
+			e := enigma.New()
+
+			err := e.AddPlugs(tc.plugs)
+			if tc.isErrorExpected == (err == nil) {
+				t.Errorf("Failed %s. Error: %v.", name, err)
+			}
+		})
+	}
+}
+
+var normaliseGermanTests = map[string]struct {
+	input    string
+	numbers  enigma.NumberStyle
+	expected string
+}{
+	"Umlauts": {
+		input:    "Fähre über Öl straße",
+		expected: "FAEHRE UEBER OEL STRASSE",
+	},
+	"CH": {
+		input:    "Achtung Buch Schiff",
+		expected: "AQTUNG BUQ SQIFF",
+	},
+	"Trailing C": {
+		input:    "Abc",
+		expected: "ABC",
+	},
+	"Period": {
+		input:    "Ende. Neu",
+		expected: "ENDEX NEU",
+	},
+	"Spelled Numbers": {
+		input:    "Kurs 268",
+		numbers:  enigma.SpellNumbers,
+		expected: "KURS ZWOSEQSAQT",
+	},
+	"Bracketed Numbers": {
+		input:    "Kurs 268 Grad",
+		numbers:  enigma.BracketNumbers,
+		expected: "KURS YWZIY GRAD",
+	},
+	"Trailing Bracketed Number": {
+		input:    "Planquadrat 40",
+		numbers:  enigma.BracketNumbers,
+		expected: "PLANKUADRAT YRPY",
+	},
+	"Q X Y": {
+		input:    "Quelle bei Xanten, Ypern",
+		expected: "KUELLE BEI KSANTEN, IPERN",
+	},
+}
+
+func TestNormaliseGerman(t *testing.T) {
+	for name, tc := range normaliseGermanTests {
+		t.Run(name, func(t *testing.T) {
+			result := enigma.NormaliseGerman(tc.input, tc.numbers)
+			if result != tc.expected {
+				t.Errorf("Failed %s.\nExpected: %s.\nResult:   %s.", name, tc.expected, result)
+			}
+		})
+	}
+}
+
+var denormaliseGermanTests = map[string]struct {
+	input    string
+	expected string
+}{
+	"Grouped": {
+		input:    "AQTUN GBUQX",
+		expected: "ACHTUNGBUCH.",
+	},
+	"Bracketed Numbers": {
+		input:    "KURSY WZIYG RAD",
+		expected: "KURS268GRAD",
+	},
+	"Unclosed Bracket": {
+		input:    "YWZ",
+		expected: "YWZ",
+	},
+	"Empty Bracket": {
+		input:    "YYA",
+		expected: "YYA",
+	},
+	"Not A Number": {
+		input:    "YAY",
+		expected: "YAY",
+	},
+}
+
+func TestDenormaliseGerman(t *testing.T) {
+	for name, tc := range denormaliseGermanTests {
+		t.Run(name, func(t *testing.T) {
+			result := enigma.DenormaliseGerman(tc.input)
+			if result != tc.expected {
+				t.Errorf("Failed %s.\nExpected: %s.\nResult:   %s.", name, tc.expected, result)
+			}
+		})
+	}
+}
+
+var germanRoundTripTests = map[string]struct {
+	input    string
+	numbers  enigma.NumberStyle
+	expected string
+}{
+	"CH And Period": {
+		input:    "Achtung. Buch",
+		expected: "ACHTUNG. BUCH",
+	},
+	"Q": {
+		input:    "Planquadrat 40",
+		numbers:  enigma.BracketNumbers,
+		expected: "PLANKUADRAT40",
+	},
+	"X": {
+		input:    "Quelle bei Xanten.",
+		expected: "KUELLEBEIKSANTEN.",
+	},
+	"Y Around Letters": {
+		input:    "Ypern und Yorck",
+		expected: "IPERNUNDIORCK",
+	},
+	"Y Around Top Row Letters": {
+		input:    "Y Quote Y",
+		numbers:  enigma.BracketNumbers,
+		expected: "IKUOTEI",
+	},
+	"Spelled Numbers": {
+		input:    "Kurs 68 bei Xanten",
+		expected: "KURSSECHSACHTBEIKSANTEN",
+	},
+	"Bracketed Numbers Next To Q": {
+		input:    "Quadrat 12 Quelle",
+		numbers:  enigma.BracketNumbers,
+		expected: "KUADRAT12KUELLE",
+	},
+}
+
+func TestGermanRoundTrip(t *testing.T) {
+	for name, tc := range germanRoundTripTests {
+		t.Run(name, func(t *testing.T) {
+			result := enigma.DenormaliseGerman(enigma.NormaliseGerman(tc.input, tc.numbers))
+			if result != tc.expected {
+				t.Errorf("Failed %s.\nExpected: %s.\nResult:   %s.", name, tc.expected, result)
+			}
+		})
+	}
+}
+
+var encodeWithOptionsTests = map[string]struct {
+	options         enigma.EncodeOptions
+	input           string
+	expected        string
+	isErrorExpected bool
+}{
+	"Default": {
+		options:  enigma.DefaultEncodeOptions,
+		input:    "Hello, world!",
+		expected: "MFNCZ BBFZM",
+	},
+	"No Grouping": {
+		options:  enigma.EncodeOptions{},
+		input:    "Hello, world!",
+		expected: "MFNCZBBFZM",
+	},
+	"Groups Of 4": {
+		options:  enigma.EncodeOptions{GroupSize: 4},
+		input:    "Hello, world!",
+		expected: "MFNC ZBBF ZM",
+	},
+	"Line Wrapping": {
+		options:  enigma.EncodeOptions{GroupSize: 2, LineGroups: 2},
+		input:    "Hello, world!",
+		expected: "MF NC\nZB BF\nZM",
+	},
+	"Preserve Layout": {
+		options:  enigma.EncodeOptions{GroupSize: 5, PreserveLayout: true},
+		input:    "Hello, world!",
+		expected: "Mfncz bbfzm",
+	},
+	"Pass Non-Letters": {
+		options:  enigma.EncodeOptions{Input: enigma.PassNonLetters},
+		input:    "Hello, world!",
+		expected: "MFNCZ, BBFZM!",
+	},
+	"Reject Non-Letters": {
+		options:         enigma.EncodeOptions{Input: enigma.RejectNonLetters},
+		input:           "Hello, world!",
+		isErrorExpected: true,
+	},
+	"Reject Allows Spaces": {
+		options:  enigma.EncodeOptions{Input: enigma.RejectNonLetters, GroupSize: 5},
+		input:    "Hello world",
+		expected: "MFNCZ BBFZM",
+	},
+	"Transliterate": {
+		options:  enigma.EncodeOptions{Input: enigma.TransliterateNonLetters, Numbers: enigma.BracketNumbers, GroupSize: 5},
+		input:    "Öl 12.",
+		expected: "DFNHV BUA",
+	},
+	"Transliterate Preserve Layout": {
+		options:  enigma.EncodeOptions{Input: enigma.TransliterateNonLetters, PreserveLayout: true},
+		input:    "Grüße aus München",
+		expected: "Ysxdivh xbb Xcsehxl",
+	},
+	"Transliterate Digraph Case": {
+		options:  enigma.EncodeOptions{Input: enigma.TransliterateNonLetters, PreserveLayout: true},
+		input:    "ÄCHTUNG Ächtung",
+		expected: "FFOURPM XGtwcwp",
+	},
+}
+
+func TestEncodeWithOptions(t *testing.T) {
+	for name, tc := range encodeWithOptionsTests {
+		t.Run(name, func(t *testing.T) {
+			e := enigma.New()
+
+			result, err := e.EncodeWithOptions(tc.input, tc.options)
+			if tc.isErrorExpected == (err == nil) {
+				t.Fatalf("Failed %s. Error: %v.", name, err)
+			}
+
+			if result != tc.expected {
+				t.Errorf("Failed %s.\nExpected: %s.\nResult:   %s.", name, tc.expected, result)
+			}
+		})
+	}
+}
+
+var pressTests = []struct {
+	key       rune
+	lamp      rune
+	positions string
+	moved     [3]bool
+}{
+	{key: 'a', lamp: 'E', positions: "ADV", moved: [3]bool{false, false, true}},
+	{key: 'A', lamp: 'Q', positions: "AEW", moved: [3]bool{false, true, true}},
+	{key: 'A', lamp: 'I', positions: "BFX", moved: [3]bool{true, true, true}},
+	{key: 'A', lamp: 'B', positions: "BFY", moved: [3]bool{false, false, true}},
+}
+
+func TestPress(t *testing.T) {
+	e := enigma.New()
+
+	err := e.SetRotor("left", "I", 1, 'A')
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = e.SetRotor("middle", "II", 1, 'D')
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = e.SetRotor("right", "III", 1, 'U')
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	for i, tc := range pressTests {
+		lamp, err := e.Press(tc.key)
+		if err != nil {
+			t.Fatalf("Failed press %d. Error: %v.", i, err)
+		}
+
+		left, middle, right := e.Moved()
+		moved := [3]bool{left, middle, right}
+
+		if lamp != tc.lamp || e.Positions() != tc.positions || moved != tc.moved {
+			t.Errorf("Failed press %d.\nExpected: %c %s %v.\nResult:   %c %s %v.", i, tc.lamp, tc.positions, tc.moved,
+				lamp, e.Positions(), moved)
+		}
+	}
+
+	_, err = e.Press('1')
+	if err == nil {
+		t.Errorf("Failed invalid key. Expected an error.")
+	}
+}
+
+func TestMovedAfterEncode(t *testing.T) {
+	e := enigma.New()
+
+	err := e.SetRotor("right", "I", 1, 'R')
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	expected := [][3]bool{{false, true, true}, {false, false, true}}
+
+	for i, exp := range expected {
+		e.Encode("A")
+
+		left, middle, right := e.Moved()
+		moved := [3]bool{left, middle, right}
+
+		if moved != exp {
+			t.Errorf("Failed encode %d.\nExpected: %v.\nResult:   %v.", i, exp, moved)
+		}
+	}
+}
+
+func TestEncodeTrace(t *testing.T) {
+	e := enigma.New()
+
+	err := e.AddPlug("AB")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	expected := []enigma.Trace{
+		{
+			Input:     'A',
+			Before:    "AAA",
+			After:     "AAB",
+			PlugIn:    'B',
+			Forward:   [3]rune{'L', 'H', 'P'},
+			Reflected: 'I',
+			Backward:  [3]rune{'Q', 'Q', 'W'},
+			Output:    'W',
+		},
+		{
+			Input:     'B',
+			Before:    "AAB",
+			After:     "AAC",
+			PlugIn:    'A',
+			Forward:   [3]rune{'K', 'L', 'V'},
+			Reflected: 'W',
+			Backward:  [3]rune{'R', 'G', 'T'},
+			Output:    'T',
+		},
+	}
+
+	result := e.EncodeTrace("a-b")
+	if len(result) != len(expected) {
+		t.Fatalf("Failed trace length.\nExpected: %d.\nResult:   %d.", len(expected), len(result))
+	}
+
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Errorf("Failed trace %d.\nExpected: %+q.\nResult:   %+q.", i, expected[i], result[i])
+		}
+	}
+}
+
+func TestOnStep(t *testing.T) {
+	e := enigma.New()
+
+	err := e.SetRotor("middle", "II", 1, 'D')
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = e.SetRotor("right", "III", 1, 'U')
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	events := []enigma.StepEvent{}
+	e.OnStep(func(event enigma.StepEvent) {
+		events = append(events, event)
+	})
+
+	count := 0
+	e.OnStep(func(enigma.StepEvent) {
+		count++
+	})
+
+	e.Encode("AAA")
+
+	expected := []enigma.StepEvent{
+		{Before: "ADU", After: "ADV", Right: true},
+		{Before: "ADV", After: "AEW", Middle: true, Right: true},
+		{Before: "AEW", After: "BFX", Left: true, Middle: true, Right: true, DoubleStep: true},
+	}
+
+	if count != len(expected) || len(events) != len(expected) {
+		t.Fatalf("Failed event count.\nExpected: %d.\nResult:   %d %d.", len(expected), len(events), count)
+	}
+
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Failed event %d.\nExpected: %+v.\nResult:   %+v.", i, expected[i], events[i])
+		}
+	}
+}
+
+func TestPlugboard(t *testing.T) {
+	e := enigma.New()
+
+	err := e.SetPlugboard("AB cd EF")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	err = e.RemovePlug("dc")
+	if err != nil {
+		t.Errorf("Failed remove. Error: %v.", err)
+	}
+
+	err = e.RemovePlug("CD")
+	if err == nil {
+		t.Errorf("Failed remove missing plug. Expected an error.")
+	}
+
+	err = e.AddPlug("GH")
+	if err != nil {
+		t.Errorf("Failed add. Error: %v.", err)
+	}
+
+	plugs := strings.Join(e.Plugs(), " ")
+	if plugs != "AB EF GH" {
+		t.Errorf("Failed plugs.\nExpected: AB EF GH.\nResult:   %s.", plugs)
+	}
+
+	err = e.SetPlugboard("IJ KL IM")
+	if err == nil {
+		t.Errorf("Failed duplicate board. Expected an error.")
+	}
+
+	plugs = strings.Join(e.Plugs(), " ")
+	if plugs != "AB EF GH" {
+		t.Errorf("Failed plugs after rejected board.\nExpected: AB EF GH.\nResult:   %s.", plugs)
+	}
+
+	e.ClearPlugs()
+
+	if len(e.Plugs()) != 0 {
+		t.Errorf("Failed clear. Plugs: %v.", e.Plugs())
+	}
+}
+
+var plugLimitTests = map[string]struct {
+	limit           int
+	plugs           []string
+	isErrorExpected bool
+}{
+	"Negative": {
+		limit:           -1,
+		isErrorExpected: true,
+	},
+	"Historical": {
+		limit: enigma.HistoricalPlugLimit,
+		plugs: []string{"AB", "CD", "EF", "GH", "IJ", "KL", "MN", "OP", "QR", "ST"},
+	},
+	"Exceeded": {
+		limit:           enigma.HistoricalPlugLimit,
+		plugs:           []string{"AB", "CD", "EF", "GH", "IJ", "KL", "MN", "OP", "QR", "ST", "UV"},
+		isErrorExpected: true,
+	},
+	"Unlimited": {
+		plugs: []string{"AB", "CD", "EF", "GH", "IJ", "KL", "MN", "OP", "QR", "ST", "UV", "WX", "YZ"},
+	},
+}
+
+func TestSetPlugLimit(t *testing.T) {
+	for name, tc := range plugLimitTests {
+		t.Run(name, func(t *testing.T) {
+			e := enigma.New()
+
+			err := e.SetPlugLimit(tc.limit)
+			if err == nil {
+				err = e.AddPlugs(tc.plugs)
+			}
+
+			if tc.isErrorExpected == (err == nil) {
+				t.Errorf("Failed %s. Error: %v.", name, err)
+			}
+		})
+	}
+}
+
+func TestAvailableRotors(t *testing.T) {
+	wheels := enigma.AvailableRotors()
+	if len(wheels) != 8 {
+		t.Fatalf("Failed rotor count.\nExpected: 8.\nResult:   %d.", len(wheels))
+	}
+
+	expected := enigma.Wheel{Name: "I", Wiring: "EKMFLGDQVZNTOWYHXUSPAIBRCJ", Notches: "R", Model: "Enigma I"}
+	if wheels[0] != expected {
+		t.Errorf("Failed first rotor.\nExpected: %+v.\nResult:   %+v.", expected, wheels[0])
+	}
+
+	if wheels[7].Name != "VIII" || wheels[7].Notches != "AN" {
+		t.Errorf("Failed last rotor. Result: %+v.", wheels[7])
+	}
+
+	for _, wheel := range wheels {
+		e := enigma.New()
+
+		err := e.SetRotor("left", wheel.Name, 1, 'A')
+		if err != nil {
+			t.Errorf("Failed rotor %s. Error: %v.", wheel.Name, err)
+		}
+	}
+}
+
+func TestAvailableReflectors(t *testing.T) {
+	wheels := enigma.AvailableReflectors()
+	if len(wheels) != 2 {
+		t.Fatalf("Failed reflector count.\nExpected: 2.\nResult:   %d.", len(wheels))
+	}
+
+	expected := enigma.Wheel{Name: "B", Wiring: "YRUHQSLDPXNGOKMIEBFZCWVJAT", Model: "Enigma I"}
+	if wheels[0] != expected {
+		t.Errorf("Failed first reflector.\nExpected: %+v.\nResult:   %+v.", expected, wheels[0])
+	}
+
+	for _, wheel := range wheels {
+		e := enigma.New()
+
+		err := e.SetReflector(wheel.Name)
+		if err != nil {
+			t.Errorf("Failed reflector %s. Error: %v.", wheel.Name, err)
+		}
+	}
+}
+
+var streamTests = map[string]struct {
+	options enigma.EncodeOptions
+	input   string
+}{
+	"Default": {
+		options: enigma.DefaultEncodeOptions,
+		input:   "The quick brown fox jumps over the lazy dog",
+	},
+	"Transliterate": {
+		options: enigma.EncodeOptions{Input: enigma.TransliterateNonLetters, GroupSize: 4, LineGroups: 3},
+		input:   "Größte Vorsicht bei 47 Schiffen. Ende",
+	},
+	"Preserve Layout": {
+		options: enigma.EncodeOptions{Input: enigma.PassNonLetters, PreserveLayout: true},
+		input:   "Über den Wolken,\nmuß die Freiheit wohl grenzenlos sein.",
+	},
+}
+
+func TestEncodingWriter(t *testing.T) {
+	for name, tc := range streamTests {
+		t.Run(name, func(t *testing.T) {
+			e := enigma.New()
+
+			expected, err := e.EncodeWithOptions(tc.input, tc.options)
+			if err != nil {
+				t.Fatalf("Setup Failed: %v.", err)
+			}
+
+			e = enigma.New()
+
+			var result strings.Builder
+			w := enigma.NewEncodingWriterWithOptions(&result, &e, tc.options)
+
+			// Write a byte at a time so that multi-byte characters are split across writes
+			for i := 0; i < len(tc.input); i++ {
+				_, err = w.Write([]byte{tc.input[i]})
+				if err != nil {
+					t.Fatalf("Failed %s. Error: %v.", name, err)
+				}
+			}
+
+			err = w.Close()
+			if err != nil {
+				t.Fatalf("Failed %s. Error: %v.", name, err)
+			}
+
+			if result.String() != expected {
+				t.Errorf("Failed %s.\nExpected: %s.\nResult:   %s.", name, expected, result.String())
+			}
+		})
+	}
+}
+
+func TestEncodingReader(t *testing.T) {
+	for name, tc := range streamTests {
+		t.Run(name, func(t *testing.T) {
+			e := enigma.New()
+
+			expected, err := e.EncodeWithOptions(tc.input, tc.options)
+			if err != nil {
+				t.Fatalf("Setup Failed: %v.", err)
+			}
+
+			e = enigma.New()
+
+			r := enigma.NewEncodingReaderWithOptions(iotest.OneByteReader(strings.NewReader(tc.input)), &e, tc.options)
+
+			result, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("Failed %s. Error: %v.", name, err)
+			}
+
+			if string(result) != expected {
+				t.Errorf("Failed %s.\nExpected: %s.\nResult:   %s.", name, expected, result)
+			}
+		})
+	}
+}
+
+func TestEncodingStreamReject(t *testing.T) {
+	options := enigma.EncodeOptions{Input: enigma.RejectNonLetters}
+
+	e := enigma.New()
+
+	w := enigma.NewEncodingWriterWithOptions(io.Discard, &e, options)
+
+	_, err := w.Write([]byte("hello, world"))
+	if err == nil {
+		t.Errorf("Failed writer. Expected an error.")
+	}
+
+	e = enigma.New()
+
+	_, err = io.ReadAll(enigma.NewEncodingReaderWithOptions(strings.NewReader("hello, world"), &e, options))
+	if err == nil {
+		t.Errorf("Failed reader. Expected an error.")
+	}
+}
+
+func TestAppendEncode(t *testing.T) {
+	e := enigma.New()
+
+	result := e.AppendEncode([]byte("Result: "), []byte("Hello, world!"))
+	if string(result) != "Result: MFNCZBBFZM" {
+		t.Errorf("Failed append.\nExpected: Result: MFNCZBBFZM.\nResult:   %s.", result)
+	}
+
+	e = enigma.New()
+	src := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100))
+	dst := make([]byte, 0, len(src))
+
+	allocations := testing.AllocsPerRun(10, func() {
+		dst = e.AppendEncode(dst[:0], src)
+	})
+
+	if allocations != 0 {
+		t.Errorf("Failed allocations.\nExpected: 0.\nResult:   %v.", allocations)
+	}
+}
+
+func TestEncodeInPlace(t *testing.T) {
+	e := enigma.New()
+
+	buf := []byte("Hello, world!")
+	e.EncodeInPlace(buf)
+
+	if string(buf) != "Mfncz, bbfzm!" {
+		t.Errorf("Failed in place.\nExpected: Mfncz, bbfzm!.\nResult:   %s.", buf)
+	}
+
+	allocations := testing.AllocsPerRun(10, func() {
+		e.EncodeInPlace(buf)
+	})
+
+	if allocations != 0 {
+		t.Errorf("Failed allocations.\nExpected: 0.\nResult:   %v.", allocations)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	e := enigma.New()
+
+	err := e.AddPlugs([]string{"AB", "CD", "EF"})
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	compiled := e.Compile()
+
+	if compiled.Period() != 16900 {
+		t.Errorf("Failed period.\nExpected: 16900.\nResult:   %d.", compiled.Period())
+	}
+
+	// Long enough to wrap around the cycle
+	input := strings.Repeat("The quick brown fox jumps over the lazy dog ", 600)
+
+	expected := e.Encode(input)
+
+	result := compiled.Encode(input)
+	if result != expected {
+		t.Errorf("Failed compiled encode. Results differ from Encode.")
+	}
+
+	appended := compiled.AppendEncode(nil, []byte(input))
+	if string(appended) != strings.ReplaceAll(expected, " ", "") {
+		t.Errorf("Failed compiled append. Results differ from Encode.")
+	}
+
+	// The enigma has moved on but the compiled tables start from where it was compiled
+	permutation := compiled.Permutation(0)
+	for i, letter := range permutation {
+		if rune(permutation[letter-'A']) != rune('A'+i) {
+			t.Errorf("Failed permutation. %s is not reciprocal.", permutation)
+			break
+		}
+	}
+
+	if compiled.Permutation(17000) != compiled.Permutation(17000-16900) {
+		t.Errorf("Failed permutation. Position 17000 does not repeat position 100.")
+	}
+
+	if compiled.Permutation(-1) != compiled.Permutation(2*16900-1) {
+		t.Errorf("Failed permutation. Position -1 does not repeat position 33799.")
+	}
+
+	if compiled.Permutation(-3*16900+5) != compiled.Permutation(2*16900+5) {
+		t.Errorf("Failed permutation. Position -50695 does not repeat position 33805.")
+	}
+}
+
+func TestEncodeParallel(t *testing.T) {
+	tc := encodeTests["Fox Pangram"]
+
+	setup := func() enigma.Enigma {
+		e := enigma.New()
+
+		err := e.SetRotor(tc.leftRotor.position, tc.leftRotor.name, tc.leftRotor.ring, tc.leftRotor.start)
+		if err == nil {
+			err = e.SetRotor(tc.middleRotor.position, tc.middleRotor.name, tc.middleRotor.ring, tc.middleRotor.start)
+		}
+
+		if err == nil {
+			err = e.SetRotor(tc.rightRotor.position, tc.rightRotor.name, tc.rightRotor.ring, tc.rightRotor.start)
+		}
+
+		if err == nil {
+			err = e.AddPlugs(tc.plugs)
+		}
+
+		if err != nil {
+			t.Fatalf("Setup Failed: %v.", err)
+		}
+
+		return e
+	}
+
+	// Long enough to give several workers a chunk each and to pass through the whole cycle of positions
+	input := strings.Repeat(tc.input+". ", 2000)
+
+	serial := setup()
+	expected := serial.Encode(input)
+
+	parallel := setup()
+	result := parallel.EncodeParallel(input, 8)
+
+	if result != expected {
+		t.Errorf("Failed parallel encode. Results differ from Encode.")
+	}
+
+	if parallel.Positions() != serial.Positions() {
+		t.Errorf("Failed positions.\nExpected: %s.\nResult:   %s.", serial.Positions(), parallel.Positions())
+	}
+	left, middle, right := serial.Moved()
+	expectedMoved := [3]bool{left, middle, right}
+
+	left, middle, right = parallel.Moved()
+	if [3]bool{left, middle, right} != expectedMoved {
+		t.Errorf("Failed moved.\nExpected: %v.\nResult:   %v.", expectedMoved, [3]bool{left, middle, right})
+	}
+
+	if parallel.EncodeParallel("", 8) != "" {
+		t.Errorf("Failed empty parallel encode. Expected no output.")
+	}
+}
+
+func TestClone(t *testing.T) {
+	e := enigma.New()
+
+	c := e.Clone()
+	c.Encode("Hello")
+
+	err := c.AddPlug("AB")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	if e.Positions() != "AAA" || len(e.Plugs()) != 0 {
+		t.Errorf("Failed clone. Original changed to %s %v.", e.Positions(), e.Plugs())
+	}
+
+	if c.Positions() != "AAF" {
+		t.Errorf("Failed clone positions.\nExpected: AAF.\nResult:   %s.", c.Positions())
+	}
+}
+
+func TestPool(t *testing.T) {
+	e := enigma.New()
+
+	err := e.AddPlugs([]string{"AB", "CD"})
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	expected := e.Clone().Encode("Hello world")
+
+	pool := enigma.NewPool(e)
+
+	// Changes to the original enigma do not reach the pool
+	e.Encode("Hello world")
+	e.ClearPlugs()
+
+	machine := pool.Get()
+	machine.Encode("Hello world")
+
+	err = machine.SetRotor("left", "VIII", 4, 'Q')
+	if err == nil {
+		err = machine.AddPlug("EF")
+	}
+
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	pool.Put(machine)
+
+	var wg sync.WaitGroup
+
+	results := make([]string, 16)
+	for i := range results {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			results[i] = pool.Encode("Hello world")
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, result := range results {
+		if result != expected {
+			t.Errorf("Failed encode %d.\nExpected: %s.\nResult:   %s.", i, expected, result)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	one, err := enigma.NewKey("B", [3]string{"V", "II", "VII"}, [3]int{15, 21, 15}, "ab fe DC")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	two, err := enigma.NewKey("B", [3]string{"V", "II", "VII"}, [3]int{15, 21, 15}, "CD EF BA")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	if one != two {
+		t.Errorf("Failed equality.\nExpected: %s.\nResult:   %s.", one, two)
+	}
+
+	if one.String() != "B V-II-VII 15-21-15 AB CD EF" {
+		t.Errorf("Failed string.\nExpected: B V-II-VII 15-21-15 AB CD EF.\nResult:   %s.", one)
+	}
+
+	seen := map[enigma.Key]bool{one: true}
+	if !seen[two] {
+		t.Errorf("Failed map key.")
+	}
+
+	tc := encodeTests["Fox Pangram"]
+
+	first, err := one.Machine("CIK")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	second, err := one.Machine("cik")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	result := first.Encode(tc.input)
+	if result != tc.expected {
+		t.Errorf("Failed machine encode.\nExpected: %s.\nResult:   %s.", tc.expected, result)
+	}
+
+	// Machines from the same key do not share positions
+	result = second.Encode(tc.input)
+	if result != tc.expected {
+		t.Errorf("Failed second machine encode.\nExpected: %s.\nResult:   %s.", tc.expected, result)
+	}
+
+	if first.Key() != one {
+		t.Errorf("Failed machine key.\nExpected: %s.\nResult:   %s.", one, first.Key())
+	}
+}
+
+var newKeyTests = map[string]struct {
+	reflector string
+	rotors    [3]string
+	rings     [3]int
+	plugs     string
+}{
+	"Invalid Reflector": {
+		reflector: "X",
+		rotors:    [3]string{"I", "II", "III"},
+		rings:     [3]int{1, 1, 1},
+	},
+	"Invalid Rotor": {
+		reflector: "B",
+		rotors:    [3]string{"I", "II", "X"},
+		rings:     [3]int{1, 1, 1},
+	},
+	"Invalid Ring": {
+		reflector: "B",
+		rotors:    [3]string{"I", "II", "III"},
+		rings:     [3]int{1, 27, 1},
+	},
+	"Invalid Plugs": {
+		reflector: "B",
+		rotors:    [3]string{"I", "II", "III"},
+		rings:     [3]int{1, 1, 1},
+		plugs:     "AB BC",
+	},
+}
+
+func TestNewKeyInvalid(t *testing.T) {
+	for name, tc := range newKeyTests {
+		t.Run(name, func(t *testing.T) {
+			_, err := enigma.NewKey(tc.reflector, tc.rotors, tc.rings, tc.plugs)
+			if err == nil {
+				t.Errorf("Failed %s. Expected an error.", name)
+			}
+		})
+	}
+}
+
+func TestEnigmaKey(t *testing.T) {
+	e := enigma.New()
+
+	err := e.AddPlugs([]string{"ZY", "AB"})
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	expected, err := enigma.NewKey("B", [3]string{"III", "II", "I"}, [3]int{1, 1, 1}, "AB YZ")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	if e.Key() != expected {
+		t.Errorf("Failed key.\nExpected: %s.\nResult:   %s.", expected, e.Key())
+	}
+
+	m, err := expected.Machine("AA")
+	if err == nil {
+		t.Errorf("Failed invalid positions. Expected an error, got %s.", m.Positions())
+	}
+}
+
+// Returns the key and positions of one of the encode tests as a candidate.
+func encodeTestCandidate(t testing.TB, name string) enigma.Candidate {
+	tc := encodeTests[name]
+
+	key, err := enigma.NewKey(tc.reflector, [3]string{tc.leftRotor.name, tc.middleRotor.name, tc.rightRotor.name},
+		[3]int{tc.leftRotor.ring, tc.middleRotor.ring, tc.rightRotor.ring}, strings.Join(tc.plugs, " "))
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	return enigma.Candidate{
+		Key:       key,
+		Positions: string([]rune{tc.leftRotor.start, tc.middleRotor.start, tc.rightRotor.start}),
+	}
+}
+
+func TestDecryptAll(t *testing.T) {
+	fox := encodeTestCandidate(t, "Fox Pangram")
+	sphinx := encodeTestCandidate(t, "Sphinx Pangram")
+	ciphertext := encodeTests["Fox Pangram"].expected
+
+	plaintexts, err := enigma.DecryptAll(ciphertext, []enigma.Candidate{fox, sphinx, fox})
+	if err != nil {
+		t.Fatalf("Failed decrypt. Error: %v.", err)
+	}
+
+	expected := "THEQUICKBROWNFOXJUMPSOVERTHELAZYDOG"
+	if plaintexts[0] != expected || plaintexts[2] != expected {
+		t.Errorf("Failed decrypt.\nExpected: %s.\nResult:   %s %s.", expected, plaintexts[0], plaintexts[2])
+	}
+
+	if plaintexts[1] == expected {
+		t.Errorf("Failed decrypt. The wrong key found the plaintext.")
+	}
+
+	_, err = enigma.DecryptAll(ciphertext, []enigma.Candidate{fox, {}})
+	if err == nil {
+		t.Errorf("Failed invalid candidate. Expected an error.")
+	}
+}
+
+func TestScoreAll(t *testing.T) {
+	fox := encodeTestCandidate(t, "Fox Pangram")
+	sphinx := encodeTestCandidate(t, "Sphinx Pangram")
+	ciphertext := encodeTests["Sphinx Pangram"].expected
+
+	countX := func(plaintext []byte) float64 {
+		return float64(strings.Count(string(plaintext), "X"))
+	}
+
+	scores, err := enigma.ScoreAll(ciphertext, []enigma.Candidate{sphinx, fox}, countX)
+	if err != nil {
+		t.Fatalf("Failed score. Error: %v.", err)
+	}
+
+	if scores[0] != 1 {
+		t.Errorf("Failed score.\nExpected: 1.\nResult:   %v.", scores[0])
+	}
+}
+
+func TestMachineStep(t *testing.T) {
+	key, err := enigma.NewKey("B", [3]string{"I", "II", "III"}, [3]int{1, 1, 1}, "AB")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	m, err := key.Machine("ADU")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	m.Step()
+	m.Step()
+
+	if m.Positions() != "AEW" {
+		t.Errorf("Failed step.\nExpected: AEW.\nResult:   %s.", m.Positions())
+	}
+
+	permutation := m.Permutation()
+
+	// Pressing a key from the position before steps to AEW and then encodes with its permutation
+	for _, letter := range "ABCDEFGHIJKLMNOPQRSTUVWXYZ" {
+		err = m.SetPositions("ADV")
+		if err != nil {
+			t.Fatalf("Failed positions. Error: %v.", err)
+		}
+
+		lamp, err := m.Press(letter)
+		if err != nil {
+			t.Fatalf("Failed press. Error: %v.", err)
+		}
+
+		if lamp != rune(permutation[letter-'A']) {
+			t.Errorf("Failed permutation of %c.\nExpected: %c.\nResult:   %c.", letter, lamp, permutation[letter-'A'])
+		}
+	}
+}
+
+func TestScrambler(t *testing.T) {
+	key, err := enigma.NewKey("B", [3]string{"I", "II", "III"}, [3]int{3, 7, 12}, "AB CD")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	s, err := key.Scrambler()
+	if err != nil {
+		t.Fatalf("Failed scrambler. Error: %v.", err)
+	}
+
+	m, err := key.Machine("AAA")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	// Includes the double step of the middle rotor from ADV
+	for _, positions := range []string{"AAA", "ADU", "ADV", "AEW", "KWM", "ZZZ"} {
+		position := enigma.PositionIndex(positions)
+
+		err = m.SetPositions(positions)
+		if err != nil {
+			t.Fatalf("Setup Failed: %v.", err)
+		}
+
+		if s.Permutation(position) != m.Permutation() {
+			t.Errorf("Failed permutation at %s.\nExpected: %s.\nResult:   %s.", positions, m.Permutation(),
+				s.Permutation(position))
+		}
+
+		if table := s.Table(position); table[0] != m.Permutation()[0]-'A' {
+			t.Errorf("Failed table at %s.\nExpected: %d.\nResult:   %d.", positions, m.Permutation()[0]-'A', table[0])
+		}
+
+		m.Step()
+
+		next := enigma.PositionLetters(s.Next(position))
+		if next != m.Positions() {
+			t.Errorf("Failed next of %s.\nExpected: %s.\nResult:   %s.", positions, m.Positions(), next)
+		}
+	}
+
+	_, err = enigma.Key{}.Scrambler()
+	if err == nil {
+		t.Errorf("Failed scrambler of the zero key. Expected an error.")
+	}
+}
+
+var positionIndexTests = map[string]struct {
+	positions string
+	expected  int
+}{
+	"First":      {"AAA", 0},
+	"Last":       {"ZZZ", enigma.PositionCount - 1},
+	"Mixed":      {"ABC", 28},
+	"Lower Case": {"abc", 28},
+	"Short":      {"AB", -1},
+	"Long":       {"ABCD", -1},
+	"Not Letter": {"A1C", -1},
+}
+
+func TestPositionIndex(t *testing.T) {
+	for name, tc := range positionIndexTests {
+		t.Run(name, func(t *testing.T) {
+			result := enigma.PositionIndex(tc.positions)
+			if result != tc.expected {
+				t.Errorf("Failed %s.\nExpected: %d.\nResult:   %d.", name, tc.expected, result)
+			}
+
+			if result >= 0 && enigma.PositionLetters(result) != strings.ToUpper(tc.positions) {
+				t.Errorf("Failed letters of %s.\nExpected: %s.\nResult:   %s.", name, strings.ToUpper(tc.positions),
+					enigma.PositionLetters(result))
+			}
+		})
+	}
+}
+
+func TestRotorOrders(t *testing.T) {
+	orders, err := enigma.RotorOrders([]string{"I", "II", "III", "IV", "V"})
+	if err != nil {
+		t.Fatalf("Failed orders. Error: %v.", err)
+	}
+
+	if len(orders) != 60 || orders[0] != [3]string{"I", "II", "III"} {
+		t.Errorf("Failed orders. Expected 60 starting with I-II-III, got %d starting with %v.", len(orders), orders[0])
+	}
+
+	for name, rotors := range map[string][]string{"Not Enough": {"I", "II"}, "No Such Rotor": {"I", "II", "X"}} {
+		_, err = enigma.RotorOrders(rotors)
+		if err == nil {
+			t.Errorf("Failed %s. Expected an error.", name)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	key, err := enigma.NewKey("B", [3]string{"II", "III", "I"}, [3]int{1, 2, 3}, "AB CD")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	m, err := key.Machine("QDV")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	ciphertext := m.Encode("Anfang der Nachricht. Wetterbericht fuer heute")
+
+	s := enigma.Search{
+		Reflectors: []string{"B"},
+		Rotors:     []string{"I", "II", "III"},
+		Rings:      [3]enigma.RingRange{{1, 1}, {1, 3}, {2, 4}},
+		Plugs:      "AB CD",
+	}
+
+	calls := 0
+	s.Progress = func(done, total int64) {
+		calls++
+
+		if done > total || total != 6*9*26*26*26 {
+			t.Errorf("Failed progress. Done %d of %d.", done, total)
+		}
+	}
+
+	candidates, err := s.Run(context.Background(), "wetterbericht", ciphertext, 18)
+	if err != nil {
+		t.Fatalf("Failed search. Error: %v.", err)
+	}
+
+	if calls != 6*9 {
+		t.Errorf("Failed progress. Expected 54 calls, got %d.", calls)
+	}
+
+	found := false
+	for _, candidate := range candidates {
+		found = found || candidate == enigma.Candidate{Key: key, Positions: "QDV"}
+
+		plaintexts, err := enigma.DecryptAll(ciphertext, []enigma.Candidate{candidate})
+		if err != nil || plaintexts[0][18:31] != "WETTERBERICHT" {
+			t.Errorf("Failed candidate %s %s. Decrypted to %v.", candidate.Key, candidate.Positions, plaintexts)
+		}
+	}
+
+	if !found {
+		t.Errorf("Failed search. Expected %s QDV among %v.", key, candidates)
+	}
+}
+
+func TestSearchLongOffset(t *testing.T) {
+	key, err := enigma.NewKey("B", [3]string{"III", "I", "II"}, [3]int{1, 1, 1}, "")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	m, err := key.Machine("KWM")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	// Further into the message than the rotors have positions
+	ciphertext := m.Encode(strings.Repeat("A", 40000) + "WETTERBERICHT")
+
+	s := enigma.Search{
+		Reflectors: []string{"B"},
+		Rotors:     []string{"I", "II", "III"},
+		Rings:      [3]enigma.RingRange{{1, 1}, {1, 1}, {1, 1}},
+	}
+
+	candidates, err := s.Run(context.Background(), "WETTERBERICHT", ciphertext, 40000)
+	if err != nil {
+		t.Fatalf("Failed search. Error: %v.", err)
+	}
+
+	expected := []enigma.Candidate{{Key: key, Positions: "KWM"}}
+	if !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("Failed search.\nExpected: %v.\nResult:   %v.", expected, candidates)
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := enigma.Search{}.Run(ctx, "WETTER", "ABCDEFGHIJ", 0)
+	if err != context.Canceled {
+		t.Errorf("Failed cancel.\nExpected: %v.\nResult:   %v.", context.Canceled, err)
+	}
+}
+
+func TestSearchCheckpoint(t *testing.T) {
+	key, err := enigma.NewKey("B", [3]string{"I", "II", "III"}, [3]int{1, 1, 1}, "")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	m, err := key.Machine("ABC")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	ciphertext := m.Encode("WETTERBERICHT")
+
+	s := enigma.Search{
+		Reflectors: []string{"B"},
+		Rotors:     []string{"I", "II", "III"},
+		Rings:      [3]enigma.RingRange{{1, 1}, {1, 1}, {1, 4}},
+		Workers:    1,
+		Checkpoint: filepath.Join(t.TempDir(), "search.json"),
+		Interval:   time.Nanosecond,
+	}
+
+	expected, err := enigma.Search{Reflectors: s.Reflectors, Rotors: s.Rotors, Rings: s.Rings}.Run(
+		context.Background(), "WETTER", ciphertext, 0)
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	// Stop part way through, then carry on from the checkpoint
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Progress = func(done, total int64) {
+		if done >= total/2 {
+			cancel()
+		}
+	}
+
+	_, err = s.Run(ctx, "WETTER", ciphertext, 0)
+	if err != context.Canceled {
+		t.Fatalf("Failed cancel.\nExpected: %v.\nResult:   %v.", context.Canceled, err)
+	}
+
+	first := int64(-1)
+	s.Progress = func(done, total int64) {
+		if first < 0 {
+			first = done
+		}
+	}
+
+	s.Resume = true
+
+	candidates, err := s.Run(context.Background(), "WETTER", ciphertext, 0)
+	if err != nil {
+		t.Fatalf("Failed resume. Error: %v.", err)
+	}
+
+	if first <= 26*26*26 {
+		t.Errorf("Failed resume. Started again after %d keys.", first)
+	}
+
+	if !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("Failed resume.\nExpected: %v.\nResult:   %v.", expected, candidates)
+	}
+
+	// A finished checkpoint has nothing left to try
+	s.Progress = func(done, total int64) {
+		t.Errorf("Failed finished resume. Tried more keys.")
+	}
+
+	candidates, err = s.Run(context.Background(), "WETTER", ciphertext, 0)
+	if err != nil || !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("Failed finished resume.\nExpected: %v.\nResult:   %v, %v.", expected, candidates, err)
+	}
+
+	_, err = s.Run(context.Background(), "REGEN", ciphertext, 0)
+	if err == nil {
+		t.Errorf("Failed resume of a different search. Expected an error.")
+	}
+}
+
+var searchInvalidTests = map[string]struct {
+	search enigma.Search
+	crib   string
+	offset int
+}{
+	"Empty Crib":    {enigma.Search{}, "12", 0},
+	"Crib Too Long": {enigma.Search{}, "WETTER", 5},
+	"Reflector":     {enigma.Search{Reflectors: []string{"Z"}}, "WETTER", 0},
+	"Rotors":        {enigma.Search{Rotors: []string{"I", "II"}}, "WETTER", 0},
+	"Rings":         {enigma.Search{Rings: [3]enigma.RingRange{{0, 27}}}, "WETTER", 0},
+	"Plugs":         {enigma.Search{Plugs: "AB BC"}, "WETTER", 0},
+}
+
+func TestSearchInvalid(t *testing.T) {
+	for name, tc := range searchInvalidTests {
+		t.Run(name, func(t *testing.T) {
+			_, err := tc.search.Run(context.Background(), tc.crib, "ABCDEFGHIJ", tc.offset)
+			if err == nil {
+				t.Errorf("Failed %s. Expected an error.", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package enigma
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NumberStyle selects how numbers are written when applying the German plaintext conventions.
+type NumberStyle int
+
+const (
+	// SpellNumbers writes each digit as its German word. e.g. 12 becomes EINSZWO.
+	SpellNumbers NumberStyle = iota
+	// BracketNumbers writes each digit as the top row key above it between Y brackets. e.g. 12 becomes YQWY.
+	BracketNumbers
+)
+
+// The German words for each digit, already following the CH to Q convention.
+var spelledDigits = [10]string{
+	"NULL", "EINS", "ZWO", "DREI", "VIER", "FUENF", "SEQS", "SIEBEN", "AQT", "NEUN",
+}
+
+// The letters on the top row of the keyboard, which share their keys with the digits 1 - 9 and 0.
+var bracketedDigits = [10]rune{'P', 'Q', 'W', 'E', 'R', 'T', 'Z', 'U', 'I', 'O'}
+
+// NormaliseGerman rewrites the input using the wartime German plaintext conventions so that it survives encoding.
+// Umlauts become digraphs (Ä to AE), ß becomes SS, CH becomes Q, a period becomes X and digits are written in the given
+// style. Q, X and Y already in the input would be mistaken for these, so they are written out as the operators did: Q
+// becomes K (QU to KU), X becomes KS and Y becomes I. Letters are returned in upper case and any other characters are
+// left unchanged.
+func NormaliseGerman(input string, numbers NumberStyle) string {
+	var result strings.Builder
+
+	n := germanNormaliser{numbers: numbers}
+	emit := func(letter rune) {
+		result.WriteRune(unicode.ToUpper(letter))
+	}
+
+	for _, letter := range input {
+		n.feed(letter, emit)
+	}
+
+	n.flush(emit)
+
+	return result.String()
+}
+
+// DenormaliseGerman undoes the German plaintext conventions in a decoded message. Numbers between Y brackets become
+// digits again, Q becomes CH and X becomes a period. Digraphs and spelled out numbers are left as they are since they
+// are already readable and cannot be told apart from ordinary words. Characters other than letters, such as the spaces
+// between groups, are ignored. The conventions lose information, so this does not give back the exact original text:
+// case, spacing and punctuation other than periods are gone, and any Q, X or Y of the original comes back as it was
+// written out by NormaliseGerman.
+func DenormaliseGerman(input string) string {
+	letters := []rune{}
+	for _, letter := range strings.ToUpper(input) {
+		if letter >= 'A' && letter <= 'Z' {
+			letters = append(letters, letter)
+		}
+	}
+
+	var result strings.Builder
+
+	for i := 0; i < len(letters); i++ {
+		switch letters[i] {
+		case 'Y':
+			digits, length := bracketedNumber(letters[i:])
+			if length == 0 {
+				result.WriteRune('Y')
+				continue
+			}
+
+			result.WriteString(digits)
+			i += length - 1
+		case 'Q':
+			result.WriteString("CH")
+		case 'X':
+			result.WriteString(". ")
+		default:
+			result.WriteRune(letters[i])
+		}
+	}
+
+	return strings.TrimSpace(result.String())
+}
+
+// Reads a number between Y brackets from the start of the letters. Returns the digits and the number of letters used
+// including both brackets, or a length of 0 if the letters do not start with a bracketed number.
+func bracketedNumber(letters []rune) (string, int) {
+	digits := ""
+
+	for i := 1; i < len(letters); i++ {
+		if letters[i] == 'Y' {
+			if len(digits) == 0 {
+				return "", 0
+			}
+
+			return digits, i + 1
+		}
+
+		digit := bracketedDigit(letters[i])
+		if digit < 0 {
+			return "", 0
+		}
+
+		digits += string(rune('0' + digit))
+	}
+
+	return "", 0
+}
+
+// Returns the digit that shares a key with the letter, or -1 if the letter is not on the top row.
+func bracketedDigit(letter rune) int {
+	for i, digit := range bracketedDigits {
+		if digit == letter {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Applies the German plaintext conventions one character at a time. Holds back a C until the next character shows
+// whether it starts a CH, and remembers whether a bracketed number is still open.
+type germanNormaliser struct {
+	numbers NumberStyle
+	// pendingC is the C held back, in the case it was given, or 0 if there is none.
+	pendingC rune
+	inNumber bool
+}
+
+// Normalises the next character of the input, passing each resulting character to emit. Letters keep the case of the
+// character they came from, so a digraph takes the case of its umlaut and a Q the case of the C of its CH. Letters
+// that stand for digits or periods are always upper case.
+func (n *germanNormaliser) feed(input rune, emit func(rune)) {
+	letter := unicode.ToUpper(input)
+
+	if n.pendingC != 0 {
+		pending := n.pendingC
+		n.pendingC = 0
+
+		if letter == 'H' {
+			emit(pending - 'C' + 'Q')
+			return
+		}
+
+		emit(pending)
+	}
+
+	// Emits a letter in the case of the input character
+	same := func(l rune) {
+		if unicode.IsLower(input) {
+			l = unicode.ToLower(l)
+		}
+
+		emit(l)
+	}
+
+	isDigit := letter >= '0' && letter <= '9'
+
+	if n.inNumber && !isDigit {
+		n.inNumber = false
+		emit('Y')
+	}
+
+	switch {
+	case letter == 'C':
+		n.pendingC = input
+	case letter == 'Ä':
+		same('A')
+		same('E')
+	case letter == 'Ö':
+		same('O')
+		same('E')
+	case letter == 'Ü':
+		same('U')
+		same('E')
+	case letter == 'ß' || letter == 'ẞ':
+		same('S')
+		same('S')
+	case letter == '.':
+		emit('X')
+	case letter == 'Q':
+		same('K')
+	case letter == 'X':
+		same('K')
+		same('S')
+	case letter == 'Y':
+		same('I')
+	case isDigit && n.numbers == BracketNumbers:
+		if !n.inNumber {
+			n.inNumber = true
+			emit('Y')
+		}
+
+		emit(bracketedDigits[letter-'0'])
+	case isDigit:
+		for _, l := range spelledDigits[letter-'0'] {
+			emit(l)
+		}
+	default:
+		same(letter)
+	}
+}
+
+// Emits anything still held back once the input has ended.
+func (n *germanNormaliser) flush(emit func(rune)) {
+	if n.pendingC != 0 {
+		emit(n.pendingC)
+		n.pendingC = 0
+	}
+
+	if n.inNumber {
+		n.inNumber = false
+		emit('Y')
+	}
+}
@@ -0,0 +1,244 @@
+package enigma
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Key is the configuration of an enigma: the reflector, the rotors and their ring settings, and the plugs. It holds no
+// running state, never changes once made and can be compared with == or used as a map key. Keys set up the same way
+// are always equal, whatever order the plugs were given in.
+type Key struct {
+	reflector string
+	rotors    [3]string
+	rings     [3]int
+	plugs     string
+}
+
+// NewKey checks and returns a key. The rotors and their ring settings are given from left to right and the plugs are
+// space separated letter pairs. e.g. "AB CD EF". Returns an error if any part of the key is invalid.
+func NewKey(reflector string, rotors [3]string, rings [3]int, plugs string) (Key, error) {
+	k := Key{
+		reflector: reflector,
+		rotors:    rotors,
+		rings:     rings,
+		plugs:     plugs,
+	}
+
+	e, err := k.enigma()
+	if err != nil {
+		return Key{}, err
+	}
+
+	return e.Key(), nil
+}
+
+// Reflector returns the name of the reflector.
+func (k Key) Reflector() string {
+	return k.reflector
+}
+
+// Rotors returns the names of the left, middle and right rotors.
+func (k Key) Rotors() [3]string {
+	return k.rotors
+}
+
+// Rings returns the ring settings of the left, middle and right rotors.
+func (k Key) Rings() [3]int {
+	return k.rings
+}
+
+// Plugs returns the space separated letter pairs of the plugs, in alphabetical order.
+func (k Key) Plugs() string {
+	return k.plugs
+}
+
+// String returns the key in a readable form. e.g. "B I-II-III 01-02-03 AB CD".
+func (k Key) String() string {
+	result := fmt.Sprintf("%s %s %02d-%02d-%02d", k.reflector, strings.Join(k.rotors[:], "-"), k.rings[0], k.rings[1],
+		k.rings[2])
+
+	if len(k.plugs) > 0 {
+		result += " " + k.plugs
+	}
+
+	return result
+}
+
+// Machine returns a working machine set up with the key and with its rotors at the given positions, from left to
+// right. e.g. "ABC". Returns an error if the positions are not 3 letters.
+func (k Key) Machine(positions string) (*Machine, error) {
+	e, err := k.enigma()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Machine{
+		key:    k,
+		enigma: e,
+	}
+
+	err = m.SetPositions(positions)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Builds an enigma set up with the key, with every rotor at position A.
+func (k Key) enigma() (Enigma, error) {
+	e := New()
+
+	err := e.SetReflector(k.reflector)
+	if err != nil {
+		return Enigma{}, err
+	}
+
+	for i, position := range []string{"left", "middle", "right"} {
+		err = e.SetRotor(position, k.rotors[i], k.rings[i], 'A')
+		if err != nil {
+			return Enigma{}, err
+		}
+	}
+
+	err = e.SetPlugboard(k.plugs)
+	if err != nil {
+		return Enigma{}, err
+	}
+
+	return e, nil
+}
+
+// Key returns the key the enigma is currently set up with.
+func (e Enigma) Key() Key {
+	pairs := make([]string, len(e.plugs))
+	for i, p := range e.plugs {
+		if p[0] > p[1] {
+			p[0], p[1] = p[1], p[0]
+		}
+
+		pairs[i] = p.String()
+	}
+
+	sort.Strings(pairs)
+
+	return Key{
+		reflector: e.reflector.name,
+		rotors:    [3]string{e.rotors[2].name, e.rotors[1].name, e.rotors[0].name},
+		rings:     [3]int{e.rotors[2].ring + 1, e.rotors[1].ring + 1, e.rotors[0].ring + 1},
+		plugs:     strings.Join(pairs, " "),
+	}
+}
+
+// Machine is a working enigma made from a Key. The key never changes but the rotor positions move on with every letter
+// encoded. A machine has rotors of its own, so machines made from the same key do not affect each other, but a single
+// machine is not safe for concurrent use.
+type Machine struct {
+	key    Key
+	enigma Enigma
+}
+
+// Key returns the key the machine was made from.
+func (m *Machine) Key() Key {
+	return m.key
+}
+
+// Reset sets the machine up with a different key, with its rotors at the given positions, reusing its existing rotors
+// and plugs rather than making new ones. Returns an error, leaving the machine unusable, if the key is the zero Key or
+// the positions are not 3 letters.
+func (m *Machine) Reset(k Key, positions string) error {
+	if k != m.key {
+		reflector, check := availableReflectors[k.reflector]
+		if !check {
+			return fmt.Errorf("no such relector: %s", k.reflector)
+		}
+
+		m.enigma.reflector = reflector
+
+		for i, name := range k.rotors {
+			rotor := availableRotors[name]
+			rotor.setRingPosition(k.rings[i])
+
+			*m.enigma.rotors[2-i] = rotor
+		}
+
+		// The plugs of a key are always pairs of letters separated by single spaces
+		m.enigma.plugs = m.enigma.plugs[:0]
+		for i := 0; i+1 < len(k.plugs); i += 3 {
+			m.enigma.plugs = append(m.enigma.plugs, plug{rune(k.plugs[i]), rune(k.plugs[i+1])})
+		}
+
+		m.key = k
+	}
+
+	return m.SetPositions(positions)
+}
+
+// Positions returns the letters currently showing in the windows of the left, middle and right rotors.
+func (m *Machine) Positions() string {
+	return m.enigma.Positions()
+}
+
+// SetPositions turns the left, middle and right rotors to the given letters. e.g. "ABC". Returns an error if the
+// positions are not 3 letters.
+func (m *Machine) SetPositions(positions string) error {
+	if len(positions) != 3 {
+		return fmt.Errorf("invalid positions: %s", positions)
+	}
+
+	starts := [3]rune{}
+	for i := range starts {
+		starts[i] = unicode.ToUpper(rune(positions[i]))
+		if starts[i] < 'A' || starts[i] > 'Z' {
+			return fmt.Errorf("invalid positions: %s", positions)
+		}
+	}
+
+	for i, start := range starts {
+		m.enigma.rotors[2-i].setStartPosition(start)
+	}
+
+	return nil
+}
+
+// Step turns the rotors as a key press would, without encoding anything.
+func (m *Machine) Step() {
+	m.enigma.step()
+}
+
+// Permutation returns the substitution the machine applies at its current positions, as the letters that each of the
+// letters A - Z become. Unlike Press, the rotors are not stepped first.
+func (m *Machine) Permutation() string {
+	table := [26]byte{}
+	for i := range table {
+		table[i] = byte(m.enigma.scramble(rune('A' + i)))
+	}
+
+	return string(table[:])
+}
+
+// Press presses a single key, stepping the rotors and encoding the letter, then returns the letter of the lamp that
+// lights up. Returns an error if the key is not a letter.
+func (m *Machine) Press(letter rune) (rune, error) {
+	return m.enigma.Press(letter)
+}
+
+// Encode takes the input string, encodes each letter in turn and returns the result in the same way as Enigma.Encode.
+func (m *Machine) Encode(input string) string {
+	return m.enigma.Encode(input)
+}
+
+// EncodeWithOptions takes the input string, encodes each letter in turn and returns the result in the same way as
+// Enigma.EncodeWithOptions.
+func (m *Machine) EncodeWithOptions(input string, options EncodeOptions) (string, error) {
+	return m.enigma.EncodeWithOptions(input, options)
+}
+
+// AppendEncode encodes each ASCII letter of src in turn and appends the result to dst in the same way as
+// Enigma.AppendEncode.
+func (m *Machine) AppendEncode(dst, src []byte) []byte {
+	return m.enigma.AppendEncode(dst, src)
+}
@@ -0,0 +1,135 @@
+package enigma
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// InputPolicy decides what happens to the characters of an input that are not letters.
+type InputPolicy int
+
+const (
+	// DropNonLetters ignores any character that is not a letter.
+	DropNonLetters InputPolicy = iota
+	// PassNonLetters copies any character that is not a letter to the output unchanged.
+	PassNonLetters
+	// RejectNonLetters returns an error for any character that is neither a letter nor white space.
+	RejectNonLetters
+	// TransliterateNonLetters applies the German plaintext conventions then ignores anything that is still not a letter.
+	TransliterateNonLetters
+)
+
+// EncodeOptions controls how the input is read and how the output is laid out.
+type EncodeOptions struct {
+	// Input is the policy for characters that are not letters.
+	Input InputPolicy
+	// Numbers is how digits are written when the input is transliterated.
+	Numbers NumberStyle
+	// GroupSize is the number of letters in each group of the output. 0 disables grouping.
+	GroupSize int
+	// LineGroups is the number of groups on each line of the output. 0 never breaks the line.
+	LineGroups int
+	// PreserveLayout keeps the case of each letter and the white space of the input instead of grouping the output.
+	// Letters from transliteration take the case of the character they came from, such as ae from ä.
+	PreserveLayout bool
+}
+
+// DefaultEncodeOptions are the options used by Encode. Non-letters are dropped and the output is grouped in fives.
+var DefaultEncodeOptions = EncodeOptions{GroupSize: 5}
+
+// Encodes characters one at a time according to a set of options, appending the result to out. Keeps count of the
+// letters and groups written so far so that the layout carries on correctly between calls.
+type encoder struct {
+	machine letterEncoder
+	options EncodeOptions
+	german  germanNormaliser
+	emit    func(rune)
+	out     []byte
+	letters int
+	groups  int
+}
+
+// Anything that steps and encodes one upper case letter at a time.
+type letterEncoder interface {
+	encodeLetter(letter rune) rune
+}
+
+func newEncoder(machine letterEncoder, options EncodeOptions) *encoder {
+	enc := &encoder{
+		machine: machine,
+		options: options,
+		german:  germanNormaliser{numbers: options.Numbers},
+	}
+
+	// Transliterated characters can only be letters or characters that are dropped, so there is never an error.
+	enc.emit = func(letter rune) {
+		_ = enc.encode(letter)
+	}
+
+	return enc
+}
+
+// Writes the next character of the input, transliterating it first if required.
+func (enc *encoder) write(letter rune) error {
+	if enc.options.Input == TransliterateNonLetters {
+		enc.german.feed(letter, enc.emit)
+		return nil
+	}
+
+	return enc.encode(letter)
+}
+
+// Writes anything held back by the transliteration once the input has ended.
+func (enc *encoder) flush() {
+	if enc.options.Input == TransliterateNonLetters {
+		enc.german.flush(enc.emit)
+	}
+}
+
+// Encodes a letter, or applies the input policy to any other character.
+func (enc *encoder) encode(input rune) error {
+	letter := unicode.ToUpper(input)
+
+	if letter < 'A' || letter > 'Z' {
+		switch {
+		case enc.options.PreserveLayout && unicode.IsSpace(input):
+			enc.out = utf8.AppendRune(enc.out, input)
+		case enc.options.Input == PassNonLetters:
+			enc.out = utf8.AppendRune(enc.out, input)
+		case enc.options.Input == RejectNonLetters && !unicode.IsSpace(input):
+			return fmt.Errorf("invalid character: %q", input)
+		}
+
+		return nil
+	}
+
+	enc.separate()
+
+	letter = enc.machine.encodeLetter(letter)
+
+	if enc.options.PreserveLayout && unicode.IsLower(input) {
+		letter = unicode.ToLower(letter)
+	}
+
+	enc.out = append(enc.out, byte(letter))
+	enc.letters++
+
+	return nil
+}
+
+// Adds a space or a line break if the next letter starts a new group.
+func (enc *encoder) separate() {
+	size := enc.options.GroupSize
+	if enc.options.PreserveLayout || size <= 0 || enc.letters == 0 || enc.letters%size != 0 {
+		return
+	}
+
+	enc.groups++
+
+	if enc.options.LineGroups > 0 && enc.groups%enc.options.LineGroups == 0 {
+		enc.out = append(enc.out, '\n')
+	} else {
+		enc.out = append(enc.out, ' ')
+	}
+}
@@ -0,0 +1,197 @@
+package enigma
+
+import (
+	"runtime"
+	"sync"
+	"unicode"
+)
+
+// The fewest letters worth handing to a worker of its own.
+const minParallelChunk = 4096
+
+// EncodeParallel takes the input string, splits its letters into chunks and encodes the chunks concurrently with up to
+// the given number of workers, returning the same result as Encode. A worker count below 1 uses GOMAXPROCS. The rotors
+// finish where Encode would leave them, but step hooks are not called.
+func (e Enigma) EncodeParallel(input string, workers int) string {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	letters := make([]byte, 0, len(input))
+	for _, letter := range input {
+		letter = unicode.ToUpper(letter)
+		if letter >= 'A' && letter <= 'Z' {
+			letters = append(letters, byte(letter))
+		}
+	}
+
+	size := max((len(letters)+workers-1)/workers, minParallelChunk)
+
+	// Each chunk starts size steps on from the one before, so every worker's copy of the rotors is turned straight to
+	// the start of its chunk
+	machines := []Enigma{}
+	if len(letters) > size {
+		after := e.rotors.positionsAfter(size)
+
+		state := e.rotors.state()
+		for start := 0; start < len(letters); start += size {
+			c := e.Clone()
+			c.stepHooks = nil
+			c.rotors.setState(state)
+
+			machines = append(machines, c)
+			state = int(after[state])
+		}
+	} else if len(letters) > 0 {
+		c := e.Clone()
+		c.stepHooks = nil
+
+		machines = append(machines, c)
+	}
+
+	var wg sync.WaitGroup
+
+	for i, c := range machines {
+		chunk := letters[i*size : min((i+1)*size, len(letters))]
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j, letter := range chunk {
+				chunk[j] = byte(c.encodeLetter(rune(letter)))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// The rotors finish where the last chunk left them
+	if len(machines) > 0 {
+		for i, r := range machines[len(machines)-1].rotors {
+			*e.rotors[i] = *r
+		}
+	}
+
+	enc := newEncoder(&encodedLetters{letters: letters}, DefaultEncodeOptions)
+	for _, letter := range letters {
+		_ = enc.encode(rune(letter))
+	}
+
+	return string(enc.out)
+}
+
+// Hands back letters that have already been encoded one at a time, so that an encoder can lay them out.
+type encodedLetters struct {
+	letters []byte
+	next    int
+}
+
+func (l *encodedLetters) encodeLetter(rune) rune {
+	letter := l.letters[l.next]
+	l.next++
+
+	return rune(letter)
+}
+
+// Returns the position index the rotors reach from every start position after the given number of steps, without
+// encoding anything. Within PositionCount steps every position falls into one of the cycles the stepping goes round,
+// so longer runs are first cut down by whole cycles. The rotors are left as they were.
+func (rotors rotors) positionsAfter(steps int) []int32 {
+	next := rotors.successors()
+
+	if steps > PositionCount {
+		steps = PositionCount + (steps-PositionCount)%period(next)
+	}
+
+	after := make([]int32, PositionCount)
+	for state := range after {
+		after[state] = int32(state)
+	}
+
+	// Square the single step until every bit of the steps has been applied
+	power := next
+	squared := make([]int32, PositionCount)
+
+	for ; steps > 0; steps >>= 1 {
+		if steps&1 == 1 {
+			for state := range after {
+				after[state] = power[after[state]]
+			}
+		}
+
+		for state := range squared {
+			squared[state] = power[power[state]]
+		}
+
+		power, squared = squared, power
+	}
+
+	return after
+}
+
+// Returns the position index the rotors step to from every position index. The rotors are left as they were.
+func (rotors rotors) successors() []int32 {
+	saved := [3]int{rotors[0].position, rotors[1].position, rotors[2].position}
+
+	next := make([]int32, PositionCount)
+	for state := range next {
+		rotors.setState(state)
+		rotors.rotate()
+
+		next[state] = int32(rotors.state())
+	}
+
+	for i, position := range saved {
+		rotors[i].position = position
+	}
+
+	return next
+}
+
+// Turns the rotors to the positions of a position index.
+func (rotors rotors) setState(state int) {
+	rotors[2].position = state / 676
+	rotors[1].position = state / 26 % 26
+	rotors[0].position = state % 26
+}
+
+// Returns the number of steps after which every position the stepping cycles through repeats, which is the lowest
+// common multiple of the lengths of every cycle.
+func period(next []int32) int {
+	// Each walk marks the positions it passes with its start, so meeting its own mark means it has found a new cycle
+	marks := make([]int32, len(next))
+	result := 1
+
+	for start := range next {
+		state := int32(start)
+		for marks[state] == 0 {
+			marks[state] = int32(start + 1)
+			state = next[state]
+		}
+
+		if marks[state] != int32(start+1) {
+			continue
+		}
+
+		length := 1
+		for s := next[state]; s != state; s = next[s] {
+			length++
+		}
+
+		result = lcm(result, length)
+	}
+
+	return result
+}
+
+// Returns the lowest common multiple of a and b.
+func lcm(a, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+
+	return a / x * b
+}
@@ -0,0 +1,202 @@
+package polish_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/jtraynor/enigma"
+	"github.com/jtraynor/enigma/polish"
+)
+
+// Returns the doubled indicators of a day's traffic sent with the key from the ground setting.
+func testIndicators(t *testing.T, key enigma.Key, ground string, count int) []string {
+	random := rand.New(rand.NewSource(1))
+
+	indicators := []string{}
+	for i := 0; i < count; i++ {
+		machine, err := key.Machine(ground)
+		if err != nil {
+			t.Fatalf("Setup Failed: %v.", err)
+		}
+
+		messageKey := []byte{}
+		for len(messageKey) < 3 {
+			messageKey = append(messageKey, byte('A'+random.Intn(26)))
+		}
+
+		indicators = append(indicators, string(machine.AppendEncode(nil, append(messageKey, messageKey...))))
+	}
+
+	return indicators
+}
+
+var productsInvalidTests = map[string][]string{
+	"Short":         {"ABCDE"},
+	"Not Letters":   {"ABC1EF"},
+	"Inconsistent":  {"ABCDEF", "AXYZUV"},
+	"Reached Twice": {"ABCDEF", "BXYDUV"},
+	"Not Enough":    {"ABCDEF"},
+}
+
+func TestProductsInvalid(t *testing.T) {
+	for name, indicators := range productsInvalidTests {
+		t.Run(name, func(t *testing.T) {
+			_, err := polish.Products(indicators)
+			if err == nil {
+				t.Errorf("Failed %s. Expected an error.", name)
+			}
+		})
+	}
+}
+
+var cyclesTests = map[string]struct {
+	permutation string
+	expected    []string
+}{
+	"Identity": {"ABCDEFGHIJKLMNOPQRSTUVWXYZ", []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M",
+		"N", "O", "P", "Q", "R", "S", "T", "U", "V", "W", "X", "Y", "Z"}},
+	"Shift": {"BCDEFGHIJKLMNOPQRSTUVWXYZA", []string{"ABCDEFGHIJKLMNOPQRSTUVWXYZ"}},
+	"Paired": {"CDBAFEHGJILKNMPORQTSVUXWZY", []string{"ACBD", "EF", "GH", "IJ", "KL", "MN", "OP", "QR", "ST", "UV", "WX",
+		"YZ"}},
+}
+
+func TestCycles(t *testing.T) {
+	for name, tc := range cyclesTests {
+		t.Run(name, func(t *testing.T) {
+			result := polish.Cycles(tc.permutation)
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Failed %s.\nExpected: %v.\nResult:   %v.", name, tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestCatalogue(t *testing.T) {
+	key, err := enigma.NewKey("B", [3]string{"II", "I", "III"}, [3]int{1, 1, 1}, "AB CD EF GH IJ KL")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	characteristic, err := polish.FindCharacteristic(testIndicators(t, key, "KWM", 200))
+	if err != nil {
+		t.Fatalf("Failed characteristic. Error: %v.", err)
+	}
+
+	for i, lengths := range characteristic {
+		total := 0
+		for _, length := range lengths {
+			total += length
+		}
+
+		if total != 26 || len(lengths)%2 != 0 {
+			t.Errorf("Failed characteristic %d. Cycles %v are not in pairs of 26 letters.", i, lengths)
+		}
+	}
+
+	catalogue, err := polish.NewCatalogue("B", [3]string{"I", "II", "III"})
+	if err != nil {
+		t.Fatalf("Failed catalogue. Error: %v.", err)
+	}
+
+	if catalogue.Len() < 1000 {
+		t.Errorf("Failed catalogue. Only %d different characteristics.", catalogue.Len())
+	}
+
+	settings := catalogue.Lookup(characteristic)
+
+	expected := polish.Setting{Rotors: [3]string{"II", "I", "III"}, Positions: "KWM"}
+
+	found := false
+	for _, setting := range settings {
+		found = found || setting == expected
+	}
+
+	if !found {
+		t.Errorf("Failed lookup of %s. Expected %s among %v.", characteristic, expected, settings)
+	}
+
+	_, err = polish.NewCatalogue("B", [3]string{"I", "I", "III"})
+	if err == nil {
+		t.Errorf("Failed catalogue of the same rotor twice. Expected an error.")
+	}
+}
+
+// Returns a day's indicators sent with the key, each a random ground setting followed by a random message key doubled
+// and encoded at it.
+func testTraffic(t *testing.T, key enigma.Key, count int) []string {
+	random := rand.New(rand.NewSource(2))
+
+	indicators := []string{}
+	for i := 0; i < count; i++ {
+		letters := []byte{}
+		for len(letters) < 6 {
+			letters = append(letters, byte('A'+random.Intn(26)))
+		}
+
+		ground, messageKey := string(letters[:3]), letters[3:]
+
+		machine, err := key.Machine(ground)
+		if err != nil {
+			t.Fatalf("Setup Failed: %v.", err)
+		}
+
+		indicators = append(indicators, ground+" "+string(machine.AppendEncode(nil, append(messageKey, messageKey...))))
+	}
+
+	return indicators
+}
+
+func TestFemales(t *testing.T) {
+	females, err := polish.Females([]string{"KWM ABCDEF", "kwm abcaef", "AAA XYZXYZ"})
+	if err != nil {
+		t.Fatalf("Failed females. Error: %v.", err)
+	}
+
+	expected := []polish.Female{
+		{Ground: "KWM", Indicator: "ABCAEF", Pair: 0},
+		{Ground: "AAA", Indicator: "XYZXYZ", Pair: 0},
+		{Ground: "AAA", Indicator: "XYZXYZ", Pair: 1},
+		{Ground: "AAA", Indicator: "XYZXYZ", Pair: 2},
+	}
+
+	if !reflect.DeepEqual(females, expected) {
+		t.Errorf("Failed females.\nExpected: %v.\nResult:   %v.", expected, females)
+	}
+
+	_, err = polish.Females([]string{"KWM ABCDE"})
+	if err == nil {
+		t.Errorf("Failed short indicator. Expected an error.")
+	}
+}
+
+func TestZygalski(t *testing.T) {
+	key, err := enigma.NewKey("B", [3]string{"III", "I", "II"}, [3]int{5, 17, 22}, "AB CD EF GH IJ KL")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	expected, err := enigma.NewKey("B", [3]string{"III", "I", "II"}, [3]int{5, 17, 22}, "")
+	if err != nil {
+		t.Fatalf("Setup Failed: %v.", err)
+	}
+
+	keys, err := polish.Zygalski{}.Run(testTraffic(t, key, 300))
+	if err != nil {
+		t.Fatalf("Failed Zygalski. Error: %v.", err)
+	}
+
+	found := false
+	for _, k := range keys {
+		found = found || k == expected
+	}
+
+	if !found || len(keys) > 5 {
+		t.Errorf("Failed Zygalski. Expected %s among a few keys, got %v.", expected, keys)
+	}
+
+	_, err = polish.Zygalski{}.Run([]string{"KWM ABCDEF"})
+	if err == nil {
+		t.Errorf("Failed Zygalski with no females. Expected an error.")
+	}
+}
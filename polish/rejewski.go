@@ -0,0 +1,230 @@
+// Package polish recovers daily keys from doubled message indicators by the methods of the Polish Cipher Bureau.
+// Before May 1940 each message key was typed twice at the daily ground setting, so the 6 letters of every indicator
+// were the same 3 letters encoded by 6 consecutive positions of the machine.
+package polish
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jtraynor/enigma"
+)
+
+// The names of the products of the permutations at each position of an indicator.
+var productNames = [3]string{"AD", "BE", "CF"}
+
+// Products returns the permutations AD, BE and CF from a day's doubled indicators, as the letters that each of the
+// letters A - Z become. AD takes the 1st letter of an indicator to the 4th, BE the 2nd to the 5th and CF the 3rd to
+// the 6th. Around 80 indicators are usually needed to see every letter. Returns an error if an indicator is not 6
+// letters, the indicators disagree or they do not give every letter of each product.
+func Products(indicators []string) ([3]string, error) {
+	products := [3][26]byte{}
+
+	for _, indicator := range indicators {
+		letters := strings.ToUpper(indicator)
+		if len(letters) != 6 || strings.Trim(letters, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return [3]string{}, fmt.Errorf("invalid indicator: %s", indicator)
+		}
+
+		for i := range products {
+			from, to := letters[i]-'A', letters[i+3]
+			if products[i][from] != 0 && products[i][from] != to {
+				return [3]string{}, fmt.Errorf("inconsistent indicator: %s", indicator)
+			}
+
+			products[i][from] = to
+		}
+	}
+
+	result := [3]string{}
+	for i, product := range products {
+		known := 0
+		seen := [26]bool{}
+
+		for _, letter := range product {
+			if letter != 0 {
+				known++
+
+				if seen[letter-'A'] {
+					return [3]string{}, fmt.Errorf("inconsistent indicators for %s: %c is reached twice", productNames[i],
+						letter)
+				}
+
+				seen[letter-'A'] = true
+			}
+		}
+
+		if known < 26 {
+			return [3]string{}, fmt.Errorf("not enough indicators for %s: %d of 26 letters", productNames[i], known)
+		}
+
+		result[i] = string(product[:])
+	}
+
+	return result, nil
+}
+
+// Cycles returns the cycles of a permutation of the letters A - Z, longest first. Each cycle starts from its first
+// letter alphabetically. e.g. "AQD".
+func Cycles(permutation string) []string {
+	cycles := []string{}
+	seen := [26]bool{}
+
+	for start := range seen {
+		if seen[start] || start >= len(permutation) {
+			continue
+		}
+
+		cycle := []byte{}
+		for letter := start; !seen[letter]; letter = int(permutation[letter] - 'A') {
+			seen[letter] = true
+			cycle = append(cycle, byte('A'+letter))
+
+			if permutation[letter] < 'A' || permutation[letter] > 'Z' {
+				break
+			}
+		}
+
+		cycles = append(cycles, string(cycle))
+	}
+
+	sort.SliceStable(cycles, func(i, j int) bool {
+		return len(cycles[i]) > len(cycles[j])
+	})
+
+	return cycles
+}
+
+// Characteristic is the lengths of the cycles of AD, BE and CF, longest first. Rejewski's insight was that the plugs
+// change which letters are in each cycle but never their lengths, so the characteristic depends only on the rotors.
+// As each product is made of two reflections, its cycles always come in pairs of the same length.
+type Characteristic [3][]int
+
+// NewCharacteristic returns the characteristic of the products AD, BE and CF.
+func NewCharacteristic(products [3]string) Characteristic {
+	c := Characteristic{}
+
+	for i, product := range products {
+		for _, cycle := range Cycles(product) {
+			c[i] = append(c[i], len(cycle))
+		}
+	}
+
+	return c
+}
+
+// FindCharacteristic returns the characteristic of a day's doubled indicators. Returns an error if the indicators do
+// not give every letter of each product.
+func FindCharacteristic(indicators []string) (Characteristic, error) {
+	products, err := Products(indicators)
+	if err != nil {
+		return Characteristic{}, err
+	}
+
+	return NewCharacteristic(products), nil
+}
+
+// String returns the characteristic in a readable form, with the cycle lengths of each product in brackets. e.g.
+// "(13 13) (10 10 3 3) (12 12 1 1)".
+func (c Characteristic) String() string {
+	products := make([]string, len(c))
+
+	for i, lengths := range c {
+		numbers := make([]string, len(lengths))
+		for j, length := range lengths {
+			numbers[j] = strconv.Itoa(length)
+		}
+
+		products[i] = "(" + strings.Join(numbers, " ") + ")"
+	}
+
+	return strings.Join(products, " ")
+}
+
+// Setting is a rotor order and the positions of the left, middle and right rotors.
+type Setting struct {
+	Rotors    [3]string
+	Positions string
+}
+
+// String returns the setting in a readable form. e.g. "I-II-III ABC".
+func (s Setting) String() string {
+	return strings.Join(s.Rotors[:], "-") + " " + s.Positions
+}
+
+// Catalogue lists every rotor order and position of a set of 3 rotors by its characteristic, as the card catalogue of
+// the Polish Cipher Bureau did. Every ring setting is taken to be 1, so the positions found are relative to the rings.
+type Catalogue struct {
+	settings map[string][]Setting
+}
+
+// NewCatalogue builds the catalogue of all 6 orders of the rotors at all 17,576 positions with the reflector. Each
+// position is stepped through exactly as the machine would, including the middle rotor turning over. Returns an error
+// if the reflector or rotors do not exist or a rotor is given twice.
+func NewCatalogue(reflector string, rotors [3]string) (*Catalogue, error) {
+	if rotors[0] == rotors[1] || rotors[0] == rotors[2] || rotors[1] == rotors[2] {
+		return nil, fmt.Errorf("rotors are not all different: %v", rotors)
+	}
+
+	orders, err := enigma.RotorOrders(rotors[:])
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalogue{settings: map[string][]Setting{}}
+
+	for _, order := range orders {
+		key, err := enigma.NewKey(reflector, order, [3]int{1, 1, 1}, "")
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := key.Scrambler()
+		if err != nil {
+			return nil, err
+		}
+
+		for position := 0; position < enigma.PositionCount; position++ {
+			characteristic := NewCharacteristic(positionProducts(s, position)).String()
+			c.settings[characteristic] = append(c.settings[characteristic], Setting{
+				Rotors:    order,
+				Positions: enigma.PositionLetters(position),
+			})
+		}
+	}
+
+	return c, nil
+}
+
+// Lookup returns every rotor order and position with the characteristic, in the order they were catalogued.
+func (c *Catalogue) Lookup(characteristic Characteristic) []Setting {
+	return append([]Setting{}, c.settings[characteristic.String()]...)
+}
+
+// Len returns the number of different characteristics in the catalogue.
+func (c *Catalogue) Len() int {
+	return len(c.settings)
+}
+
+// Returns the products AD, BE and CF of the 6 positions the rotors step through from a position index.
+func positionProducts(s *enigma.Scrambler, position int) [3]string {
+	tables := [6]*[26]uint8{}
+	for i := range tables {
+		position = s.Next(position)
+		tables[i] = s.Table(position)
+	}
+
+	products := [3]string{}
+	for i := range products {
+		product := [26]byte{}
+		for letter := range product {
+			product[letter] = 'A' + tables[i+3][tables[i][letter]]
+		}
+
+		products[i] = string(product[:])
+	}
+
+	return products
+}
@@ -0,0 +1,274 @@
+package polish
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/jtraynor/enigma"
+)
+
+// Every left ring setting.
+const allRings = 1<<26 - 1
+
+// Female is an indicator with the same letter twice, 3 apart. From late 1938 each message was sent with a ground
+// setting of the operator's choosing in the clear, followed by the message key doubled and encoded at it. A female can
+// only happen at positions where the product of the permutations 3 apart has a letter it leaves alone.
+type Female struct {
+	// Ground is the ground setting sent in the clear.
+	Ground string
+	// Indicator is the doubled message key as encoded.
+	Indicator string
+	// Pair is the first of the positions with the same letter, from 0 for the 1st and 4th letters to 2 for the 3rd
+	// and 6th.
+	Pair int
+}
+
+// Females returns every female among a day's indicators. Each indicator is the 3 letters of the ground setting
+// followed by the 6 letters of the doubled message key, with anything other than letters ignored. e.g. "KWM RTZ UWQ".
+// An indicator with more than one repeat gives a female for each. Returns an error if an indicator is not 9 letters.
+func Females(indicators []string) ([]Female, error) {
+	females := []Female{}
+
+	for _, indicator := range indicators {
+		letters := strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'A' && r <= 'Z':
+				return r
+			case r >= 'a' && r <= 'z':
+				return r - 'a' + 'A'
+			}
+
+			return -1
+		}, indicator)
+
+		if len(letters) != 9 {
+			return nil, fmt.Errorf("invalid indicator: %s", indicator)
+		}
+
+		for pair := 0; pair < 3; pair++ {
+			if letters[3+pair] == letters[6+pair] {
+				females = append(females, Female{Ground: letters[:3], Indicator: letters[3:], Pair: pair})
+			}
+		}
+	}
+
+	return females, nil
+}
+
+// Sheets are Zygalski's perforated sheets for a single rotor order, which show every position at which a female can
+// happen. Rather than the 26 sheets of card the Poles cut by hand, the permutation of every position of the rotors is
+// kept, so that the holes can be punched for any ring setting as they are needed.
+type Sheets struct {
+	scrambler *enigma.Scrambler
+}
+
+// NewSheets returns the sheets for an order of rotors with the reflector. Returns an error if the reflector or rotors
+// do not exist.
+func NewSheets(reflector string, rotors [3]string) (*Sheets, error) {
+	key, err := enigma.NewKey(reflector, rotors, [3]int{1, 1, 1}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := key.Scrambler()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sheets{scrambler: s}, nil
+}
+
+// Stack returns every ring setting of the left, middle and right rotors at which all of the females fall on holes,
+// in order of ring setting. For each ring setting of the middle and right rotors, each female punches a sheet of the
+// left ring settings at which it could happen, and the sheets are stacked to see which left ring settings shine
+// through all of them. The positions the rotors step through only depend on the letters in their windows, so the
+// ring settings only change which part of the wiring is met, and every turnover is taken into account.
+func (s *Sheets) Stack(females []Female) [][3]int {
+	if len(females) == 0 {
+		return [][3]int{}
+	}
+
+	windows, err := s.windows(females)
+	if err != nil {
+		return [][3]int{}
+	}
+
+	survivors := [][3]int{}
+
+	for middle := 1; middle <= 26; middle++ {
+		for right := 1; right <= 26; right++ {
+			stack := uint32(allRings)
+
+			for _, w := range windows {
+				sheet := uint32(0)
+				for left := 1; left <= 26; left++ {
+					if s.hole(w, [3]int{left, middle, right}) {
+						sheet |= 1 << (left - 1)
+					}
+				}
+
+				stack &= sheet
+				if stack == 0 {
+					break
+				}
+			}
+
+			for ; stack != 0; stack &= stack - 1 {
+				survivors = append(survivors, [3]int{bits.TrailingZeros32(stack) + 1, middle, right})
+			}
+		}
+	}
+
+	sort.Slice(survivors, func(i, j int) bool {
+		a, b := survivors[i], survivors[j]
+		return a[0] < b[0] || a[0] == b[0] && (a[1] < b[1] || a[1] == b[1] && a[2] < b[2])
+	})
+
+	return survivors
+}
+
+// Returns the position indexes of the letters in the windows when each of the pair of letters of every female was
+// encoded. Returns an error if a ground setting is not 3 letters.
+func (s *Sheets) windows(females []Female) ([][2]int, error) {
+	windows := make([][2]int, len(females))
+
+	for i, female := range females {
+		position := enigma.PositionIndex(female.Ground)
+		if position < 0 {
+			return nil, fmt.Errorf("invalid ground setting: %s", female.Ground)
+		}
+
+		for press := 0; press < 6; press++ {
+			position = s.scrambler.Next(position)
+
+			switch press {
+			case female.Pair:
+				windows[i][0] = position
+			case female.Pair + 3:
+				windows[i][1] = position
+			}
+		}
+	}
+
+	return windows, nil
+}
+
+// Returns whether a female is possible with the ring settings when the pair of letters were encoded with the rotors
+// showing the windows, which is when the product of the pair of permutations leaves a letter alone.
+func (s *Sheets) hole(windows [2]int, rings [3]int) bool {
+	first := s.scrambler.Table(corePosition(windows[0], rings))
+	second := s.scrambler.Table(corePosition(windows[1], rings))
+
+	for letter := range first {
+		if second[first[letter]] == uint8(letter) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Zygalski finds the rotor order and ring settings of a day's traffic from the females among its indicators, by
+// stacking Zygalski's sheets for every rotor order. Each survivor is confirmed on an enigma before it is returned. The
+// plugs are left to be found by other means.
+type Zygalski struct {
+	// Reflector is the name of the reflector to use. Defaults to B.
+	Reflector string
+	// Rotors are the names of the rotors to try in every order. Defaults to I - III.
+	Rotors []string
+}
+
+// Run returns a key with no plugs for each rotor order and ring settings at which every female among the indicators
+// is possible, in order of rotor order and then ring settings. Returns an error if an indicator is invalid, there are
+// no females or the reflector or rotors do not exist.
+func (z Zygalski) Run(indicators []string) ([]enigma.Key, error) {
+	females, err := Females(indicators)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(females) == 0 {
+		return nil, fmt.Errorf("no females among %d indicators", len(indicators))
+	}
+
+	reflector := z.Reflector
+	if len(reflector) == 0 {
+		reflector = "B"
+	}
+
+	rotors := z.Rotors
+	if len(rotors) == 0 {
+		rotors = []string{"I", "II", "III"}
+	}
+
+	orders, err := enigma.RotorOrders(rotors)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []enigma.Key{}
+
+	for _, order := range orders {
+		sheets, err := NewSheets(reflector, order)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rings := range sheets.Stack(females) {
+			key, err := enigma.NewKey(reflector, order, rings, "")
+			if err != nil {
+				return nil, err
+			}
+
+			if confirm(key, females) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// Returns whether every female is possible on an enigma set up with the key at its ground setting.
+func confirm(key enigma.Key, females []Female) bool {
+	for _, female := range females {
+		machine, err := key.Machine(female.Ground)
+		if err != nil {
+			return false
+		}
+
+		permutations := [6]string{}
+		for i := range permutations {
+			machine.Step()
+			permutations[i] = machine.Permutation()
+		}
+
+		first, second := permutations[female.Pair], permutations[female.Pair+3]
+
+		fixed := false
+		for letter := range first {
+			fixed = fixed || second[first[letter]-'A'] == byte('A'+letter)
+		}
+
+		if !fixed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the index of the position of the wiring of the rotors, given the position index of the letters in their
+// windows and their rings.
+func corePosition(windows int, rings [3]int) int {
+	letters := [3]int{windows / 676, windows / 26 % 26, windows % 26}
+
+	position := 0
+	for i := range rings {
+		position = position*26 + (letters[i]-rings[i]+27)%26
+	}
+
+	return position
+}
@@ -0,0 +1,72 @@
+package enigma
+
+import "sync"
+
+// Pool hands out independent working machines that all start from the same configuration and positions. The pool is
+// safe for concurrent use, so one pool holding the daily key can be shared by every goroutine that needs to encode.
+type Pool struct {
+	template Enigma
+	machines sync.Pool
+}
+
+// NewPool returns a pool of machines configured as the enigma is now, at its current positions. Later changes to the
+// enigma do not affect the pool. Step hooks are not carried over since they may not be safe for concurrent use.
+func NewPool(e Enigma) *Pool {
+	p := &Pool{
+		template: e.Clone(),
+	}
+
+	p.template.stepHooks = nil
+
+	p.machines.New = func() any {
+		machine := p.template.Clone()
+		return &machine
+	}
+
+	return p
+}
+
+// Get returns a machine at the starting positions of the pool for the sole use of the caller. It may be changed freely
+// and should be given back with Put once it is finished with.
+func (p *Pool) Get() *Enigma {
+	machine := p.machines.Get().(*Enigma)
+
+	p.reset(machine)
+
+	return machine
+}
+
+// Put gives a machine from Get back to the pool. The machine must not be used again afterwards.
+func (p *Pool) Put(machine *Enigma) {
+	p.machines.Put(machine)
+}
+
+// Encode takes the input string, encodes each letter in turn starting from the positions of the pool and returns the
+// result in the same way as Enigma.Encode. Every call uses a machine of its own.
+func (p *Pool) Encode(input string) string {
+	machine := p.Get()
+	defer p.Put(machine)
+
+	return machine.Encode(input)
+}
+
+// EncodeWithOptions takes the input string, encodes each letter in turn starting from the positions of the pool and
+// returns the result in the same way as Enigma.EncodeWithOptions. Every call uses a machine of its own.
+func (p *Pool) EncodeWithOptions(input string, options EncodeOptions) (string, error) {
+	machine := p.Get()
+	defer p.Put(machine)
+
+	return machine.EncodeWithOptions(input, options)
+}
+
+// Sets the machine back to the configuration and positions of the pool, reusing its rotors and plugs.
+func (p *Pool) reset(machine *Enigma) {
+	for i, r := range p.template.rotors {
+		*machine.rotors[i] = *r
+	}
+
+	machine.reflector = p.template.reflector
+	machine.plugs = append(machine.plugs[:0], p.template.plugs...)
+	machine.plugLimit = p.template.plugLimit
+	machine.stepHooks = nil
+}
@@ -0,0 +1,115 @@
+package enigma
+
+import "fmt"
+
+// PositionCount is the number of different positions of the rotors, 26 for each of the 3 rotors. Positions are
+// numbered from 0 for AAA to PositionCount - 1 for ZZZ, with the right rotor changing fastest.
+const PositionCount = 26 * 26 * 26
+
+// PositionLetters returns the window letters of the left, middle and right rotors for a position index. e.g. 28 is
+// "ABC".
+func PositionLetters(position int) string {
+	return string([]byte{byte('A' + position/676), byte('A' + position/26%26), byte('A' + position%26)})
+}
+
+// PositionIndex returns the position index for the window letters of the left, middle and right rotors, or -1 if they
+// are not 3 letters.
+func PositionIndex(positions string) int {
+	if len(positions) != 3 {
+		return -1
+	}
+
+	index := 0
+	for i := 0; i < 3; i++ {
+		letter := upperLetter(positions[i])
+		if letter == 0 {
+			return -1
+		}
+
+		index = index*26 + int(letter-'A')
+	}
+
+	return index
+}
+
+// RotorOrders returns every order of 3 different rotors from the names, from left to right. Returns an error if any of
+// the rotors do not exist or there are fewer than 3 different rotors.
+func RotorOrders(rotors []string) ([][3]string, error) {
+	for _, name := range rotors {
+		if _, check := availableRotors[name]; !check {
+			return nil, fmt.Errorf("no such rotor: %s", name)
+		}
+	}
+
+	orders := [][3]string{}
+	for _, left := range rotors {
+		for _, middle := range rotors {
+			for _, right := range rotors {
+				if left != middle && left != right && middle != right {
+					orders = append(orders, [3]string{left, middle, right})
+				}
+			}
+		}
+	}
+
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("not enough rotors: %v", rotors)
+	}
+
+	return orders, nil
+}
+
+// Scrambler holds the substitution a key applies at every one of the rotor positions, along with the position the
+// rotors step on to from each. Where Compiled follows the rotors through a single message, a Scrambler covers every
+// start position at once, for attacks that try them all. A Scrambler is never changed once made and is safe to share.
+type Scrambler struct {
+	tables [PositionCount][26]uint8
+	next   [PositionCount]int32
+}
+
+// Scrambler works out the substitution and the next position of the key at every rotor position. Returns an error if
+// the key is the zero Key.
+func (k Key) Scrambler() (*Scrambler, error) {
+	e, err := k.enigma()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scrambler{}
+
+	for state := range s.tables {
+		e.rotors.setState(state)
+
+		for j := range s.tables[state] {
+			s.tables[state][j] = uint8(e.scramble(rune('A'+j)) - 'A')
+		}
+
+		e.rotors.rotate()
+		s.next[state] = int32(e.rotors.state())
+	}
+
+	return s, nil
+}
+
+// Table returns the substitution at a position index as the numbers 0 - 25 that each of the letters A - Z become, for
+// code that indexes with it. The position must be between 0 and PositionCount - 1.
+func (s *Scrambler) Table(position int) *[26]uint8 {
+	return &s.tables[position]
+}
+
+// Permutation returns the substitution at a position index as the letters that each of the letters A - Z become. The
+// position must be between 0 and PositionCount - 1.
+func (s *Scrambler) Permutation(position int) string {
+	table := [26]byte{}
+	for i, letter := range s.tables[position] {
+		table[i] = 'A' + letter
+	}
+
+	return string(table[:])
+}
+
+// Next returns the position index the rotors step on to from a position index when a key is pressed. The position must
+// be between 0 and PositionCount - 1.
+func (s *Scrambler) Next(position int) int {
+	return int(s.next[position])
+}
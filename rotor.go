@@ -1,147 +1,168 @@
-package enigma
-
-type rotor struct {
-	alphabetRing  [26]rune
-	substitutions [26]rune
-	triggers      []rune
-}
-
-type rotors [3]*rotor
-
-// Encodes a letter through each rotor in turn. If inverse is false then the letter passes from right to left. If inverse is
-// true then the letter passes from left to right.
-func (rotors rotors) encode(letter rune, inverse bool) rune {
-	result := letter
-
-	if inverse {
-		for i := 2; i >= 0; i-- {
-			result = rotors[i].inverseEncode(result)
-		}
-	} else {
-		for i := 0; i <= 2; i++ {
-			result = rotors[i].encode(result)
-		}
-	}
-
-	return result
-}
-
-// Encodes a letter from the right side of the rotor to the left.
-func (rotor *rotor) encode(letter rune) rune {
-	result := letter
-
-	subLetter := rotor.substitutions[letter-'A']
-
-	for i := range rotor.alphabetRing {
-		if rotor.alphabetRing[i] == subLetter {
-			result = rune(i + 'A')
-			break
-		}
-	}
-
-	return result
-}
-
-// Encodes a letter from the left side of the rotor to the right.
-func (rotor *rotor) inverseEncode(letter rune) rune {
-	result := letter
-
-	ringLetter := rotor.alphabetRing[letter-'A']
-
-	for i := range rotor.substitutions {
-		if rotor.substitutions[i] == ringLetter {
-			result = rune(i + 'A')
-			break
-		}
-	}
-
-	return result
-}
-
-// Rotates the rotor one position.
-func (rotor *rotor) rotate() {
-	ring := [26]rune{}
-	substitutions := [26]rune{}
-	for i := 0; i < 26; i++ {
-		ring[i] = rotor.alphabetRing[(i+1)%26]
-		substitutions[i] = rotor.substitutions[(i+1)%26]
-	}
-	rotor.alphabetRing = ring
-	rotor.substitutions = substitutions
-}
-
-// Rotates the right rotor and handles any subsequent rotations caused by each rotors triggers.
-func (rotors rotors) rotate() {
-	rightRotor := rotors[0]
-	middleRotor := rotors[1]
-	leftRotor := rotors[2]
-
-	rightRotor.rotate()
-
-	if rightRotor.checkTrigger() {
-		middleRotor.rotate()
-	}
-
-	if rotors.checkDoubleStep() {
-		middleRotor.rotate()
-		leftRotor.rotate()
-	}
-}
-
-// Checks if the letter that was in the window when this rotation began is one of this rotors triggers to rotate the next
-// rotor along. Assumes this rotor has already been rotated.
-func (rotor rotor) checkTrigger() bool {
-	for _, trigger := range rotor.triggers {
-		if trigger == rotor.alphabetRing[25] {
-			return true
-		}
-	}
-
-	return false
-}
-
-// Checks if this rotation will trigger a double step. If the right rotor has rotated the middle rotor last rotation and the
-// middle rotor will rotate the left rotor on its next rotation then a double step occurs.
-func (rotors rotors) checkDoubleStep() bool {
-	rightRotor := rotors[0]
-	middleRotor := rotors[1]
-
-	rightCheck := false
-	for _, trigger := range rightRotor.triggers {
-		if trigger == rightRotor.alphabetRing[24] {
-			rightCheck = true
-		}
-	}
-
-	middleCheck := false
-	for _, trigger := range middleRotor.triggers {
-		if trigger == middleRotor.alphabetRing[0] {
-			middleCheck = true
-		}
-	}
-
-	return rightCheck && middleCheck
-}
-
-// Shifts the substituation in relation to the alphabet ring by the number of positions provided.
-func (rotor *rotor) setRingPosition(position int) {
-	increase := position - 1
-
-	substitutions := [26]rune{}
-	for i := 0; i < 26; i++ {
-		substitutions[(i+increase)%26] = increaseLetter(rotor.substitutions[i], increase)
-	}
-	rotor.substitutions = substitutions
-}
-
-// Rotates the rotor so that the start position is as provided.
-func (rotor *rotor) setStartPosition(start rune) {
-	for i := 0; i < int(start-'A'); i++ {
-		rotor.rotate()
-	}
-}
-
-// Returns a letter the provided number of letters more than the letter provided. Wraps back to A after Z.
-func increaseLetter(letter rune, increase int) rune {
-	return 'A' + ((letter - 'A' + rune(increase)) % 26)
-}
+package enigma
+
+// A rotor is a fixed wiring table plus its inverse, turned against the contacts by its position and ring setting. All
+// letters inside the rotor are held as numbers between 0 - 25.
+type rotor struct {
+	name     string
+	wiring   [26]uint8
+	inverse  [26]uint8
+	triggers []rune
+	model    string
+	ring     int
+	position int
+	// turned is whether the rotor turned on the most recent key press. It is kept on the rotor rather than the enigma
+	// so that it is shared by every copy of the enigma, as the positions are.
+	turned bool
+}
+
+type rotors [3]*rotor
+
+// Returns a rotor at position A with a ring setting of 1. The wiring lists the letter each of the letters A - Z is wired
+// to and the triggers are the letters which rotate the next rotor along.
+func newRotor(name, wiring, triggers, model string) rotor {
+	rotor := rotor{
+		name:     name,
+		triggers: []rune(triggers),
+		model:    model,
+	}
+
+	for i := 0; i < 26; i++ {
+		letter := wiring[i] - 'A'
+		rotor.wiring[i] = letter
+		rotor.inverse[letter] = uint8(i)
+	}
+
+	return rotor
+}
+
+// Encodes a letter through each rotor in turn. If inverse is false then the letter passes from right to left. If inverse is
+// true then the letter passes from left to right.
+func (rotors rotors) encode(letter rune, inverse bool) rune {
+	result := letter
+
+	if inverse {
+		for i := 2; i >= 0; i-- {
+			result = rotors[i].inverseEncode(result)
+		}
+	} else {
+		for i := 0; i <= 2; i++ {
+			result = rotors[i].encode(result)
+		}
+	}
+
+	return result
+}
+
+// Encodes a letter from the right side of the rotor to the left.
+func (rotor *rotor) encode(letter rune) rune {
+	offset := rotor.position - rotor.ring
+	contact := wrap(int(letter-'A') + offset)
+
+	return 'A' + rune(wrap(int(rotor.wiring[contact])-offset))
+}
+
+// Encodes a letter from the left side of the rotor to the right.
+func (rotor *rotor) inverseEncode(letter rune) rune {
+	offset := rotor.position - rotor.ring
+	contact := wrap(int(letter-'A') + offset)
+
+	return 'A' + rune(wrap(int(rotor.inverse[contact])-offset))
+}
+
+// Rotates the rotor one position.
+func (rotor *rotor) rotate() {
+	rotor.position++
+	if rotor.position == 26 {
+		rotor.position = 0
+	}
+}
+
+// Rotates the right rotor and handles any subsequent rotations caused by each rotors triggers. Returns which rotors were
+// rotated, in the same order as the rotors, and whether a double step occurred.
+func (rotors rotors) rotate() ([3]bool, bool) {
+	rightRotor := rotors[0]
+	middleRotor := rotors[1]
+	leftRotor := rotors[2]
+
+	moved := [3]bool{true, false, false}
+
+	rightRotor.rotate()
+
+	if rightRotor.checkTrigger() {
+		middleRotor.rotate()
+		moved[1] = true
+	}
+
+	doubleStep := rotors.checkDoubleStep()
+	if doubleStep {
+		middleRotor.rotate()
+		leftRotor.rotate()
+		moved[1] = true
+		moved[2] = true
+	}
+
+	for i, rotor := range rotors {
+		rotor.turned = moved[i]
+	}
+
+	return moved, doubleStep
+}
+
+// Returns the letter currently showing in the window of the rotor.
+func (rotor rotor) window() rune {
+	return 'A' + rune(rotor.position)
+}
+
+// Returns the letters currently showing in the windows of the rotors, from left to right.
+func (rotors rotors) positions() string {
+	return string([]rune{rotors[2].window(), rotors[1].window(), rotors[0].window()})
+}
+
+// Checks if the letter that was in the window when this rotation began is one of this rotors triggers to rotate the next
+// rotor along. Assumes this rotor has already been rotated.
+func (rotor *rotor) checkTrigger() bool {
+	return rotor.isTrigger(wrap(rotor.position - 1))
+}
+
+// Checks if this rotation will trigger a double step. If the right rotor has rotated the middle rotor last rotation and the
+// middle rotor will rotate the left rotor on its next rotation then a double step occurs.
+func (rotors rotors) checkDoubleStep() bool {
+	rightRotor := rotors[0]
+	middleRotor := rotors[1]
+
+	return rightRotor.isTrigger(wrap(rightRotor.position-2)) && middleRotor.isTrigger(middleRotor.position)
+}
+
+// Checks if the letter at the given position is one of this rotors triggers.
+func (rotor *rotor) isTrigger(position int) bool {
+	for _, trigger := range rotor.triggers {
+		if int(trigger-'A') == position {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Shifts the wiring in relation to the alphabet ring by the number of positions provided.
+func (rotor *rotor) setRingPosition(position int) {
+	rotor.ring = position - 1
+}
+
+// Rotates the rotor so that the start position is as provided.
+func (rotor *rotor) setStartPosition(start rune) {
+	rotor.position = int(start - 'A')
+}
+
+// Wraps a number between -26 and 51 back into the range 0 - 25.
+func wrap(n int) int {
+	if n < 0 {
+		return n + 26
+	}
+
+	if n >= 26 {
+		return n - 26
+	}
+
+	return n
+}
@@ -0,0 +1,325 @@
+package enigma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// RingRange is the ring settings to try for a single rotor, from First to Last. The zero RingRange tries every ring
+// setting from 1 to 26.
+type RingRange struct {
+	First int
+	Last  int
+}
+
+// Search tries every key in a space of keys against a known piece of plaintext, called a crib, and reports the keys
+// that encode it to the ciphertext. Every reflector, every order of 3 different rotors, every ring setting in range
+// and every start position is tried. The plugs are not searched, so every key uses the same plugs.
+type Search struct {
+	// Reflectors are the names of the reflectors to try. Defaults to every available reflector.
+	Reflectors []string
+	// Rotors are the names of the rotors to try in every order. Defaults to every available rotor.
+	Rotors []string
+	// Rings are the ring settings to try for the left, middle and right rotors.
+	Rings [3]RingRange
+	// Plugs are the space separated letter pairs of the plugs used with every key. e.g. "AB CD EF".
+	Plugs string
+	// Workers is the number of keys tried at once. Defaults to GOMAXPROCS.
+	Workers int
+	// Progress, if set, is called after each batch of keys with the number of keys tried so far out of the total.
+	// It is called from the goroutine that called Run, never concurrently.
+	Progress func(done, total int64)
+	// Checkpoint, if set, is the path of a file the progress of the search and the matches found so far are saved to
+	// at intervals and when the search stops, whether it finished, was cancelled or failed.
+	Checkpoint string
+	// Interval is how often the checkpoint is saved. Defaults to a minute.
+	Interval time.Duration
+	// Resume carries on the search from the checkpoint, if the file exists, rather than starting again.
+	Resume bool
+}
+
+// The keys a search tries, split into jobs of every start position for a single reflector, rotor order and set of
+// ring settings.
+type searchSpace struct {
+	reflectors []string
+	orders     [][3]string
+	rings      [3]RingRange
+	plugs      string
+	jobs       int
+}
+
+// Run searches for the keys that encode the crib to the ciphertext, with the crib starting at the given offset into
+// the ciphertext, counting from 0. Anything other than letters is ignored in both. Returns a candidate for each match,
+// with the positions the rotors start from at the beginning of the ciphertext. If the context is cancelled the search
+// stops early, returning the matches found so far along with the context's error. Returns an error if the crib does
+// not fit in the ciphertext, the search uses wheels, rings or plugs that are invalid, the checkpoint being resumed is
+// for a different search or the checkpoint could not be saved.
+func (s Search) Run(ctx context.Context, crib, ciphertext string, offset int) ([]Candidate, error) {
+	plain := AppendLetters(nil, []byte(crib))
+	cipher := AppendLetters(nil, []byte(ciphertext))
+
+	if len(plain) == 0 {
+		return nil, fmt.Errorf("empty crib: %s", crib)
+	}
+
+	if offset < 0 || offset+len(plain) > len(cipher) {
+		return nil, fmt.Errorf("crib does not fit at offset %d: %s", offset, crib)
+	}
+
+	space, err := s.space()
+	if err != nil {
+		return nil, err
+	}
+
+	progress := space.checkpoint(plain, cipher, offset)
+
+	if s.Resume && len(s.Checkpoint) > 0 {
+		saved, err := loadCheckpoint(s.Checkpoint)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// Nothing has been saved yet, so the search starts from the beginning
+		case err != nil:
+			return nil, err
+		case !reflect.DeepEqual(saved.Search, progress.Search) || saved.Cursor < 0 || saved.Cursor > space.jobs:
+			return nil, fmt.Errorf("checkpoint is for a different search: %s", s.Checkpoint)
+		default:
+			progress = saved
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := s.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	type result struct {
+		job     int
+		matches []Candidate
+	}
+
+	jobs := make(chan int)
+	results := make(chan result)
+
+	go func() {
+		defer close(jobs)
+
+		for job := progress.Cursor; job < space.jobs && ctx.Err() == nil; job++ {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	starts := space.starts(offset)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			m := &Machine{enigma: New()}
+
+			for job := range jobs {
+				matches := space.search(m, job, plain, cipher[offset:offset+len(plain)], starts[space.order(job)])
+				results <- result{job: job, matches: matches}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Jobs finish out of order, so those after the cursor wait here until every job before them has finished
+	finished := map[int][]Candidate{}
+
+	done := int64(progress.Cursor) * PositionCount
+	total := int64(space.jobs) * PositionCount
+
+	saved := time.Now()
+	var failed error
+
+	for r := range results {
+		finished[r.job] = r.matches
+		progress.advance(finished)
+
+		done += PositionCount
+		if s.Progress != nil {
+			s.Progress(done, total)
+		}
+
+		if len(s.Checkpoint) > 0 && failed == nil && time.Since(saved) >= s.interval() {
+			failed = progress.save(s.Checkpoint)
+			if failed != nil {
+				cancel()
+			}
+
+			saved = time.Now()
+		}
+	}
+
+	if len(s.Checkpoint) > 0 && failed == nil {
+		failed = progress.save(s.Checkpoint)
+	}
+
+	candidates := space.candidates(progress, finished)
+
+	if failed != nil {
+		return candidates, failed
+	}
+
+	return candidates, ctx.Err()
+}
+
+// Returns how often the checkpoint is saved.
+func (s Search) interval() time.Duration {
+	if s.Interval <= 0 {
+		return time.Minute
+	}
+
+	return s.Interval
+}
+
+// Checks the wheels, rings and plugs of the search and works out how many jobs it has.
+func (s Search) space() (searchSpace, error) {
+	space := searchSpace{
+		reflectors: s.Reflectors,
+		rings:      s.Rings,
+		plugs:      s.Plugs,
+	}
+
+	if len(space.reflectors) == 0 {
+		space.reflectors = reflectorNames
+	}
+
+	rotors := s.Rotors
+	if len(rotors) == 0 {
+		rotors = rotorNames
+	}
+
+	orders, err := RotorOrders(rotors)
+	if err != nil {
+		return searchSpace{}, err
+	}
+
+	space.orders = orders
+
+	for i, r := range space.rings {
+		if r == (RingRange{}) {
+			space.rings[i] = RingRange{First: 1, Last: 26}
+		}
+
+		if space.rings[i].First < 1 || space.rings[i].Last > 26 || space.rings[i].First > space.rings[i].Last {
+			return searchSpace{}, fmt.Errorf("invalid ring range: %d - %d", r.First, r.Last)
+		}
+	}
+
+	// The rotors are all checked, so the reflectors and plugs are checked up front with any one order of them rather
+	// than part way through the search
+	for _, reflector := range space.reflectors {
+		_, err := NewKey(reflector, space.orders[0], [3]int{1, 1, 1}, space.plugs)
+		if err != nil {
+			return searchSpace{}, err
+		}
+	}
+
+	space.jobs = len(space.reflectors) * len(space.orders)
+	for _, r := range space.rings {
+		space.jobs *= r.Last - r.First + 1
+	}
+
+	return space, nil
+}
+
+// Returns the key for a job. The right ring changes fastest, then the middle and left rings, then the rotor order and
+// finally the reflector.
+func (space searchSpace) key(job int) Key {
+	rings := [3]int{}
+	for i := 2; i >= 0; i-- {
+		size := space.rings[i].Last - space.rings[i].First + 1
+
+		rings[i] = space.rings[i].First + job%size
+		job /= size
+	}
+
+	order := space.orders[job%len(space.orders)]
+	reflector := space.reflectors[job/len(space.orders)]
+
+	// The wheels and plugs were all checked before the search started
+	key, _ := NewKey(reflector, order, rings, space.plugs)
+
+	return key
+}
+
+// Returns the index of the rotor order of a job.
+func (space searchSpace) order(job int) int {
+	for _, r := range space.rings {
+		job /= r.Last - r.First + 1
+	}
+
+	return job % len(space.orders)
+}
+
+// Returns, for each rotor order, the position index the rotors are in when the crib begins from every start position.
+// The rotors step on their window letters and notches alone, so this is the same for every reflector and ring setting.
+func (space searchSpace) starts(offset int) [][]int32 {
+	starts := make([][]int32, len(space.orders))
+
+	for i, order := range space.orders {
+		// The wheels were all checked before the search started
+		key, _ := NewKey(space.reflectors[0], order, [3]int{1, 1, 1}, "")
+		e, _ := key.enigma()
+
+		starts[i] = e.rotors.positionsAfter(offset)
+	}
+
+	return starts
+}
+
+// Tries every start position of a job, with the rotors turned straight to where they are when the crib begins, and
+// returns those that encode the crib to the ciphertext.
+func (space searchSpace) search(m *Machine, job int, plain, cipher []byte, starts []int32) []Candidate {
+	matches := []Candidate{}
+
+	key := space.key(job)
+
+	err := m.Reset(key, "AAA")
+	if err != nil {
+		return matches
+	}
+
+	rotors := m.enigma.rotors
+
+	for start := 0; start < PositionCount; start++ {
+		rotors.setState(int(starts[start]))
+
+		match := true
+		for i, letter := range cipher {
+			if m.enigma.encodeLetter(rune(letter)) != rune(plain[i]) {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			matches = append(matches, Candidate{Key: key, Positions: PositionLetters(start)})
+		}
+	}
+
+	return matches
+}
@@ -0,0 +1,45 @@
+package enigma
+
+// StepEvent describes how the rotors stepped for a single key press.
+type StepEvent struct {
+	// Before and After are the letters showing in the windows of the left, middle and right rotors either side of the
+	// rotors stepping.
+	Before string
+	After  string
+	// Left, Middle and Right report whether each rotor turned.
+	Left   bool
+	Middle bool
+	Right  bool
+	// DoubleStep reports whether the middle rotor turned a second time, taking the left rotor with it.
+	DoubleStep bool
+}
+
+// OnStep registers a hook that is called with the details of every key press once the rotors have stepped. Hooks are
+// called in the order they were registered, before the letter is encoded.
+func (e *Enigma) OnStep(hook func(StepEvent)) {
+	e.stepHooks = append(e.stepHooks, hook)
+}
+
+// Steps the rotors and notifies any hooks. The window positions are only worked out when a hook is waiting for them.
+func (e *Enigma) step() {
+	if len(e.stepHooks) == 0 {
+		e.rotors.rotate()
+		return
+	}
+
+	event := StepEvent{
+		Before: e.rotors.positions(),
+	}
+
+	moved, doubleStep := e.rotors.rotate()
+
+	event.After = e.rotors.positions()
+	event.Left = moved[2]
+	event.Middle = moved[1]
+	event.Right = moved[0]
+	event.DoubleStep = doubleStep
+
+	for _, hook := range e.stepHooks {
+		hook(event)
+	}
+}
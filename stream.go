@@ -0,0 +1,171 @@
+package enigma
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// EncodingWriter encodes everything written to it and writes the result to an underlying writer as it goes.
+type EncodingWriter struct {
+	w       io.Writer
+	enc     *encoder
+	partial []byte
+}
+
+// NewEncodingWriter returns a writer that encodes with the enigma, laid out in the same way as Encode, and writes the
+// result to w. Close must be called once everything has been written.
+func NewEncodingWriter(w io.Writer, e *Enigma) *EncodingWriter {
+	return NewEncodingWriterWithOptions(w, e, DefaultEncodeOptions)
+}
+
+// NewEncodingWriterWithOptions returns a writer that encodes with the enigma, laid out as the options describe, and
+// writes the result to w. Close must be called once everything has been written.
+func NewEncodingWriterWithOptions(w io.Writer, e *Enigma, options EncodeOptions) *EncodingWriter {
+	return &EncodingWriter{
+		w:   w,
+		enc: newEncoder(e, options),
+	}
+}
+
+// Write encodes p and writes the result to the underlying writer. A character split across two writes is held back
+// until the rest of it arrives. Returns an error if the input policy rejects a character or the underlying write fails.
+func (w *EncodingWriter) Write(p []byte) (int, error) {
+	held := len(w.partial)
+
+	data := p
+	if held > 0 {
+		data = append(w.partial, p...)
+	}
+
+	used, err := w.enc.writeBytes(data, false)
+
+	w.partial = append(w.partial[:0], data[used:]...)
+
+	werr := w.writeOut()
+	if err == nil {
+		err = werr
+	}
+
+	if err != nil {
+		return max(used-held, 0), err
+	}
+
+	return len(p), nil
+}
+
+// Close encodes anything held back and writes it to the underlying writer. The underlying writer is not closed.
+func (w *EncodingWriter) Close() error {
+	_, err := w.enc.writeBytes(w.partial, true)
+
+	w.partial = w.partial[:0]
+	w.enc.flush()
+
+	werr := w.writeOut()
+	if err == nil {
+		err = werr
+	}
+
+	return err
+}
+
+// Writes the encoded output to the underlying writer, ready for the next write.
+func (w *EncodingWriter) writeOut() error {
+	if len(w.enc.out) == 0 {
+		return nil
+	}
+
+	_, err := w.w.Write(w.enc.out)
+
+	w.enc.out = w.enc.out[:0]
+
+	return err
+}
+
+// EncodingReader encodes everything read from an underlying reader as it goes.
+type EncodingReader struct {
+	r       io.Reader
+	enc     *encoder
+	buffer  []byte
+	partial int
+	out     []byte
+	err     error
+}
+
+// NewEncodingReader returns a reader that encodes everything read from r with the enigma, laid out in the same way as
+// Encode.
+func NewEncodingReader(r io.Reader, e *Enigma) *EncodingReader {
+	return NewEncodingReaderWithOptions(r, e, DefaultEncodeOptions)
+}
+
+// NewEncodingReaderWithOptions returns a reader that encodes everything read from r with the enigma, laid out as the
+// options describe.
+func NewEncodingReaderWithOptions(r io.Reader, e *Enigma, options EncodeOptions) *EncodingReader {
+	return &EncodingReader{
+		r:      r,
+		enc:    newEncoder(e, options),
+		buffer: make([]byte, 32*1024),
+	}
+}
+
+// Read reads from the underlying reader and fills p with the encoded result. Returns io.EOF once the underlying reader
+// is exhausted, or an error if the input policy rejects a character.
+func (r *EncodingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		r.fill()
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+// Reads the next chunk from the underlying reader and encodes it. A character split across two chunks is kept at the
+// start of the buffer until the rest of it arrives.
+func (r *EncodingReader) fill() {
+	r.enc.out = r.enc.out[:0]
+
+	n, err := r.r.Read(r.buffer[r.partial:])
+	data := r.buffer[:r.partial+n]
+
+	used, encodeErr := r.enc.writeBytes(data, err != nil)
+
+	r.partial = copy(r.buffer, data[used:])
+
+	switch {
+	case encodeErr != nil:
+		r.err = encodeErr
+	case err != nil:
+		r.enc.flush()
+		r.err = err
+	}
+
+	r.out = r.enc.out
+}
+
+// Writes each character of the UTF-8 encoded data in turn. Stops before a character that is cut short at the end of the
+// data unless this is the final data. Returns the number of bytes used.
+func (enc *encoder) writeBytes(data []byte, final bool) (int, error) {
+	used := 0
+
+	for used < len(data) {
+		if !final && !utf8.FullRune(data[used:]) {
+			break
+		}
+
+		letter, size := utf8.DecodeRune(data[used:])
+
+		err := enc.write(letter)
+		if err != nil {
+			return used, err
+		}
+
+		used += size
+	}
+
+	return used, nil
+}
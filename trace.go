@@ -0,0 +1,71 @@
+package enigma
+
+import "strings"
+
+// Trace records the path of a single letter through the machine.
+type Trace struct {
+	// Input is the letter of the key that was pressed.
+	Input rune
+	// Before and After are the letters showing in the windows of the left, middle and right rotors either side of the
+	// rotors stepping.
+	Before string
+	After  string
+	// PlugIn is the letter after passing through the plugs on the way in.
+	PlugIn rune
+	// Forward is the letter after passing through each of the right, middle and left rotors on the way in.
+	Forward [3]rune
+	// Reflected is the letter after passing through the reflector.
+	Reflected rune
+	// Backward is the letter after passing through each of the left, middle and right rotors on the way out.
+	Backward [3]rune
+	// Output is the letter after passing through the plugs on the way out, which is the lamp that lights up.
+	Output rune
+}
+
+// EncodeTrace takes the input string, encodes each letter in turn and returns the trace of every letter. Input
+// characters that are not letters are ignored.
+func (e Enigma) EncodeTrace(input string) []Trace {
+	traces := []Trace{}
+
+	for _, letter := range strings.ToUpper(input) {
+		if letter < 'A' || letter > 'Z' {
+			continue
+		}
+
+		traces = append(traces, e.traceLetter(letter))
+	}
+
+	return traces
+}
+
+// Encodes a single upper case letter in the same way as encodeLetter, recording every intermediate letter.
+func (e *Enigma) traceLetter(letter rune) Trace {
+	trace := Trace{
+		Input:  letter,
+		Before: e.rotors.positions(),
+	}
+
+	e.step()
+
+	trace.After = e.rotors.positions()
+
+	letter = e.plugs.replace(letter)
+	trace.PlugIn = letter
+
+	for i := 0; i <= 2; i++ {
+		letter = e.rotors[i].encode(letter)
+		trace.Forward[i] = letter
+	}
+
+	letter = e.reflector.encode(letter)
+	trace.Reflected = letter
+
+	for i := 2; i >= 0; i-- {
+		letter = e.rotors[i].inverseEncode(letter)
+		trace.Backward[2-i] = letter
+	}
+
+	trace.Output = e.plugs.replace(letter)
+
+	return trace
+}
@@ -0,0 +1,46 @@
+package enigma
+
+// Wheel describes a rotor or reflector that can be fitted to the enigma.
+type Wheel struct {
+	// Name is the name used to select the wheel. e.g. "IV".
+	Name string
+	// Wiring is the letter each of the letters A - Z is wired to, in alphabetical order.
+	Wiring string
+	// Notches are the letters which turn the next rotor along when they are showing in the window as this rotor turns.
+	// Reflectors have no notches.
+	Notches string
+	// Model is the machine the wheel was made for.
+	Model string
+}
+
+// AvailableRotors returns a description of every rotor that can be passed to SetRotor, in order.
+func AvailableRotors() []Wheel {
+	return describeWheels(rotorNames, availableRotors)
+}
+
+// AvailableReflectors returns a description of every reflector that can be passed to SetReflector, in order.
+func AvailableReflectors() []Wheel {
+	return describeWheels(reflectorNames, availableReflectors)
+}
+
+// Describes each of the named rotors in turn.
+func describeWheels(names []string, available map[string]rotor) []Wheel {
+	wheels := make([]Wheel, len(names))
+	for i, name := range names {
+		rotor := available[name]
+
+		wiring := make([]byte, 26)
+		for j, letter := range rotor.wiring {
+			wiring[j] = 'A' + letter
+		}
+
+		wheels[i] = Wheel{
+			Name:    name,
+			Wiring:  string(wiring),
+			Notches: string(rotor.triggers),
+			Model:   rotor.model,
+		}
+	}
+
+	return wheels
+}